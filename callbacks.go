@@ -0,0 +1,199 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsEnabled reports whether the callback is enabled, which it is unless its enabled
+// field is false.
+func (cb CallbackDefinition) IsEnabled() bool {
+	return cb.Enabled == nil || *cb.Enabled
+}
+
+// sortCallbacks sorts callbacks by decreasing priority, keeping callbacks with the
+// same priority in the order they are defined.
+func sortCallbacks(callbacks []CallbackDefinition) {
+	slices.SortStableFunc(callbacks, func(a, b CallbackDefinition) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+}
+
+// SetCallbackEnabled enables or disables the callback with the given name in the
+// sequence of callbacks at the dot-notation prefix of the YAML or JSON data, and
+// returns the updated data. Only the enabled value is rewritten, or an enabled: false
+// entry inserted, so the rest of the data is kept byte for byte, comments and
+// formatting included. Enabling a callback without an enabled field leaves data
+// unchanged. Data is YAML unless WithFormat says otherwise; TOML is not supported.
+// An error wrapping ErrUnknownCallback is returned if there is no such callback.
+func SetCallbackEnabled(data []byte, prefix, name string, enabled bool, opts ...Option) ([]byte, error) {
+	format := newOptions(opts).format
+	switch format {
+	case FormatAuto:
+		format = FormatYAML
+	case FormatTOML:
+		return nil, fmt.Errorf("cannot set enabled of callback %q in place: only YAML and JSON are supported", name)
+	}
+	doc, err := parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	// The JSON parser reports columns in bytes, the YAML parser in characters.
+	at := func(line, column int) int { return offset(data, line, column) }
+	if format == FormatJSON {
+		at = func(line, column int) int { return offset(data, line, 1) + column - 1 }
+	}
+	callbacksNode, err := lookupSection(doc, prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	var item *yaml.Node
+	var names []string
+	if callbacksNode != nil {
+		if callbacksNode.Kind != yaml.SequenceNode {
+			return nil, malformedSection(prefix, callbacksNode, "a sequence of callbacks", nil)
+		}
+		for _, node := range callbacksNode.Content {
+			if nameNode := mappingValue(node, "name"); nameNode != nil {
+				names = append(names, nameNode.Value)
+				if nameNode.Value == name && item == nil {
+					item = node
+				}
+			}
+		}
+	}
+	if item == nil {
+		err := fmt.Errorf("%w %q in %s", ErrUnknownCallback, name, prefix)
+		if s := suggest(name, names); s != "" {
+			err = fmt.Errorf("%w (did you mean %q?)", err, s)
+		}
+		return nil, err
+	}
+
+	value := strconv.FormatBool(enabled)
+	var updated []byte
+	if _, node := mappingEntry(item, "enabled"); node != nil {
+		// Replace the existing value.
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("enabled of callback %q%s is not a boolean", name, position("", node.Line, node.Column))
+		}
+		start := at(node.Line, node.Column)
+		updated = splice(data, start, scalarEnd(data, start), value)
+	} else if enabled {
+		return data, nil
+	} else if format == FormatJSON {
+		// Insert the member before the first one, on its own line if that is on a
+		// line of its own.
+		first := item.Content[0]
+		start := at(first.Line, first.Column)
+		entry := `"enabled": false, `
+		if first.Line > item.Line {
+			entry = `"enabled": false,` + "\n" + string(data[at(first.Line, 1):start])
+		}
+		updated = splice(data, start, start, entry)
+	} else if item.Style&yaml.FlowStyle != 0 {
+		// Insert the entry before the first key of a {name: ..., ...} mapping.
+		first := item.Content[0]
+		start := offset(data, first.Line, first.Column)
+		updated = splice(data, start, start, "enabled: false, ")
+	} else {
+		// Insert the entry on its own line, after a key whose value fits on the key's
+		// line and at the key's indentation.
+		var key *yaml.Node
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			k, v := item.Content[i], item.Content[i+1]
+			if v.Line == k.Line && (v.Kind == yaml.ScalarNode && v.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 || v.Style&yaml.FlowStyle != 0) {
+				key = k
+				break
+			}
+		}
+		if key == nil {
+			return nil, fmt.Errorf("cannot insert enabled into callback %q%s", name, position("", item.Line, item.Column))
+		}
+		next := offset(data, key.Line+1, 1)
+		entry := strings.Repeat(" ", key.Column-1) + "enabled: false\n"
+		if next == len(data) && next > 0 && data[next-1] != '\n' {
+			entry = "\n" + strings.TrimSuffix(entry, "\n")
+		}
+		updated = splice(data, next, next, entry)
+	}
+
+	// Check that the edit did what was intended, in case the layout of the callback
+	// defeated the positions used above.
+	var check struct {
+		Enabled *bool `yaml:"enabled"`
+	}
+	doc, err = parse(updated, format)
+	if err == nil {
+		var node *yaml.Node
+		if node, err = lookupSection(doc, prefix, nil); err == nil && node != nil && node.Kind == yaml.SequenceNode {
+			for _, n := range node.Content {
+				if nameNode := mappingValue(n, "name"); nameNode != nil && nameNode.Value == name {
+					err = n.Decode(&check)
+					break
+				}
+			}
+		}
+	}
+	if err != nil || check.Enabled == nil || *check.Enabled != enabled {
+		return nil, fmt.Errorf("cannot set enabled of callback %q in place", name)
+	}
+	return updated, nil
+}
+
+// offset returns the byte offset in data of the 1-based line and column reported by
+// the YAML parser, which counts columns in characters. A line past the end of data
+// gives len(data).
+func offset(data []byte, line, column int) int {
+	i := 0
+	for l := 1; l < line; l++ {
+		nl := bytes.IndexByte(data[i:], '\n')
+		if nl < 0 {
+			return len(data)
+		}
+		i += nl + 1
+	}
+	for c := 1; c < column && i < len(data) && data[i] != '\n'; c++ {
+		_, size := utf8.DecodeRune(data[i:])
+		i += size
+	}
+	return i
+}
+
+// scalarEnd returns the offset just past the single-line scalar starting at start.
+func scalarEnd(data []byte, start int) int {
+	if start < len(data) && (data[start] == '"' || data[start] == '\'') {
+		quote := data[start]
+		for i := start + 1; i < len(data) && data[i] != '\n'; i++ {
+			switch {
+			case data[i] == '\\' && quote == '"':
+				i++
+			case data[i] == quote && quote == '\'' && i+1 < len(data) && data[i+1] == '\'':
+				i++
+			case data[i] == quote:
+				return i + 1
+			}
+		}
+		return start
+	}
+	end := start
+	for end < len(data) && strings.IndexByte(" \t\r\n,]}#", data[end]) < 0 {
+		end++
+	}
+	return end
+}
+
+// splice returns a copy of data with data[start:end] replaced by s.
+func splice(data []byte, start, end int, s string) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(s))
+	out = append(out, data[:start]...)
+	out = append(out, s...)
+	return append(out, data[end:]...)
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestCallbackOrder(t *testing.T) {
+	doc, err := yamledit.Parse([]byte(`callbacks:
+  - {name: "a", events: ["write"], timing: "pre", target: {type: "file", path: "/a"}, endpoints: []}
+  - {name: "b", events: ["write"], timing: "pre", target: {type: "file", path: "/a"}, endpoints: [], priority: 10}
+  - {name: "c", events: ["write"], timing: "pre", target: {type: "file", path: "/a"}, endpoints: [], priority: -1}
+  - {name: "d", events: ["write"], timing: "pre", target: {type: "file", path: "/a"}, endpoints: [], enabled: false}
+  - {name: "e", events: ["write"], timing: "pre", target: {type: "file", path: "/a"}, endpoints: [], priority: 10}
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	callbacks, err := ProcessCallbacks(doc, "callbacks", &Variables{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := names(callbacks); got != "b e a d c" {
+		t.Errorf("expected callbacks b e a d c, got %s", got)
+	}
+	if callbacks[3].IsEnabled() || !callbacks[0].IsEnabled() {
+		t.Errorf("unexpected enabled flags %v, %v", callbacks[3].Enabled, callbacks[0].Enabled)
+	}
+	if got := names(NewMatcher(callbacks).Match("write", "pre", "/a")); got != "b e a c" {
+		t.Errorf("expected matches b e a c, got %s", got)
+	}
+}
+
+// names returns the space-separated names of callbacks.
+func names(callbacks []CallbackDefinition) string {
+	s := ""
+	for i, cb := range callbacks {
+		if i > 0 {
+			s += " "
+		}
+		s += cb.Name
+	}
+	return s
+}
+
+func TestSetCallbackEnabled(t *testing.T) {
+	const config = `# hooks
+callbacks:
+  - name: "scan"   # virus scan
+    events: ["write"]
+    timing:    "pre"
+    target:
+      type: file
+      path: /a
+    endpoints: []
+  - {name: flow, events: [write], timing: post, target: {type: file, path: /b}, endpoints: []}
+  - target: {type: file, path: /c}
+    name: 'quoted'
+    enabled: "true" # keep this comment`
+	cases := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{"scan", false, `# hooks
+callbacks:
+  - name: "scan"   # virus scan
+    enabled: false
+    events: ["write"]
+    timing:    "pre"
+    target:
+      type: file
+      path: /a
+    endpoints: []
+  - {name: flow, events: [write], timing: post, target: {type: file, path: /b}, endpoints: []}
+  - target: {type: file, path: /c}
+    name: 'quoted'
+    enabled: "true" # keep this comment`},
+		{"scan", true, config},
+		{"flow", false, `# hooks
+callbacks:
+  - name: "scan"   # virus scan
+    events: ["write"]
+    timing:    "pre"
+    target:
+      type: file
+      path: /a
+    endpoints: []
+  - {enabled: false, name: flow, events: [write], timing: post, target: {type: file, path: /b}, endpoints: []}
+  - target: {type: file, path: /c}
+    name: 'quoted'
+    enabled: "true" # keep this comment`},
+		{"quoted", false, `# hooks
+callbacks:
+  - name: "scan"   # virus scan
+    events: ["write"]
+    timing:    "pre"
+    target:
+      type: file
+      path: /a
+    endpoints: []
+  - {name: flow, events: [write], timing: post, target: {type: file, path: /b}, endpoints: []}
+  - target: {type: file, path: /c}
+    name: 'quoted'
+    enabled: false # keep this comment`},
+	}
+	for _, c := range cases {
+		got, err := SetCallbackEnabled([]byte(config), "callbacks", c.name, c.enabled)
+		if err != nil {
+			t.Fatalf("SetCallbackEnabled(%q, %v) returned error: %v", c.name, c.enabled, err)
+		}
+		if string(got) != c.want {
+			t.Errorf("SetCallbackEnabled(%q, %v):\n%s\nwant:\n%s", c.name, c.enabled, got, c.want)
+		}
+	}
+
+	// The last line of a file without a trailing newline.
+	got, err := SetCallbackEnabled([]byte("callbacks:\n  - name: last"), "callbacks", "last", false)
+	if want := "callbacks:\n  - name: last\n    enabled: false"; err != nil || string(got) != want {
+		t.Errorf("expected %q, got %q (%v)", want, got, err)
+	}
+
+	// JSON keeps valid JSON, with the member on its own line when the others are.
+	jsonCases := []struct {
+		config, want string
+	}{
+		{`{"callbacks": [{"name": "a", "events": ["write"]}]}`,
+			`{"callbacks": [{"enabled": false, "name": "a", "events": ["write"]}]}`},
+		{"{\n  \"callbacks\": [\n    {\n      \"name\": \"a\"\n    }\n  ]\n}\n",
+			"{\n  \"callbacks\": [\n    {\n      \"enabled\": false,\n      \"name\": \"a\"\n    }\n  ]\n}\n"},
+		{`{"callbacks": [{"name": "a", "enabled": true}]}`,
+			`{"callbacks": [{"name": "a", "enabled": false}]}`},
+	}
+	for _, c := range jsonCases {
+		got, err := SetCallbackEnabled([]byte(c.config), "callbacks", "a", false, WithFormat(FormatJSON))
+		if err != nil {
+			t.Fatalf("SetCallbackEnabled on %s returned error: %v", c.config, err)
+		}
+		if string(got) != c.want {
+			t.Errorf("SetCallbackEnabled on %s:\n%s\nwant:\n%s", c.config, got, c.want)
+		}
+		if !json.Valid(got) {
+			t.Errorf("SetCallbackEnabled on %s returned invalid JSON %s", c.config, got)
+		}
+	}
+
+	// TOML cannot be edited in place.
+	toml := "[[callbacks]]\nname = \"a\"\n"
+	if _, err := SetCallbackEnabled([]byte(toml), "callbacks", "a", false, WithFormat(FormatTOML)); err == nil {
+		t.Error("expected an error for TOML")
+	}
+
+	_, err = SetCallbackEnabled([]byte(config), "callbacks", "scna", false)
+	if !errors.Is(err, ErrUnknownCallback) {
+		t.Fatalf("expected ErrUnknownCallback, got %v", err)
+	}
+	if want := `unknown callback "scna" in callbacks (did you mean "scan"?)`; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"github.com/dropsite-ai/config"
 	"github.com/dropsite-ai/yamledit"
+	"gopkg.in/yaml.v3"
 )
 
 func usage() {
 	fmt.Println("Usage:")
-	fmt.Println("  cli load -config <path>")
-	fmt.Println("  cli copy -srcfile <src.yaml> -srcpath <dot.path> -dstfile <dst.yaml> -dstpath <dot.path>")
+	fmt.Println("  cli load -config <path|dir|->")
+	fmt.Println("  cli validate -config <path|dir|-> [-schema <schema.json>] [-strict=false]")
+	fmt.Println("  cli enable -config <path|-> -callback <name>")
+	fmt.Println("  cli disable -config <path|-> -callback <name>")
+	fmt.Println("  cli copy -srcfile <src.yaml|-> -srcpath <dot.path> -dstfile <dst.yaml|-> -dstpath <dot.path>")
+	fmt.Println("Use - to read from stdin (and, for -dstfile, enable and disable, write to stdout).")
 }
 
 func main() {
@@ -25,6 +32,12 @@ func main() {
 	switch cmd {
 	case "load":
 		loadCmd(os.Args[2:])
+	case "validate":
+		validateCmd(os.Args[2:])
+	case "enable":
+		toggleCmd("enable", os.Args[2:], true)
+	case "disable":
+		toggleCmd("disable", os.Args[2:], false)
 	case "copy":
 		copyCmd(os.Args[2:])
 	default:
@@ -33,20 +46,20 @@ func main() {
 	}
 }
 
-// loadCmd loads a config file, processes it, and then displays the YAML document along with
-// the processed Variables and CallbackDefinition values.
+// loadCmd loads a config file (or a directory of config fragments), processes it, and then
+// displays the YAML document along with the processed Variables and CallbackDefinition values.
 func loadCmd(args []string) {
 	fs := flag.NewFlagSet("load", flag.ExitOnError)
-	configPath := fs.String("config", "", "Path to YAML config file")
+	configPath := fs.String("config", "", "Path to YAML config file or directory of YAML files, or - for stdin")
 	fs.Parse(args)
 	if *configPath == "" {
 		fs.Usage()
 		os.Exit(1)
 	}
 
-	doc, vars, callbacks, err := config.Load(*configPath, []byte{})
+	doc, vars, callbacks, err := loadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
+		fatalConfigError("Error loading config", err)
 	}
 
 	// Encode the YAML document back into bytes for display.
@@ -62,21 +75,126 @@ func loadCmd(args []string) {
 	fmt.Printf("%+v\n", callbacks)
 }
 
+// validateCmd checks a config file (or a directory of config fragments) against the
+// built-in schema and an optional application schema, then processes it (rejecting
+// unknown keys unless -strict=false), reporting every problem found. Overlapping
+// callbacks are reported as warnings.
+func validateCmd(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to YAML config file or directory of YAML files, or - for stdin")
+	schemaPath := fs.String("schema", "", "Path to a JSON Schema (JSON or YAML) the whole document must match")
+	strict := fs.Bool("strict", true, "Reject unknown callback fields and variables sections")
+	fs.Parse(args)
+	if *configPath == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := []config.Option{config.WithSchema(config.BuiltinSchema())}
+	if *strict {
+		opts = append(opts, config.WithStrict())
+	}
+	if *schemaPath != "" {
+		schemaBytes, err := os.ReadFile(*schemaPath)
+		if err != nil {
+			log.Fatalf("Error reading schema: %v", err)
+		}
+		schema, err := config.CompileSchema(schemaBytes)
+		if err != nil {
+			log.Fatalf("Error compiling schema: %v", err)
+		}
+		opts = append(opts, config.WithSchema(schema))
+	}
+	_, _, callbacks, err := loadConfig(*configPath, opts...)
+	if err != nil {
+		fatalConfigError("Invalid config", err)
+	}
+	for _, warning := range config.LintCallbacks(callbacks) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	fmt.Printf("%s is valid\n", *configPath)
+}
+
+// loadConfig loads a config file, a directory of config fragments, or stdin if path is "-".
+func loadConfig(path string, opts ...config.Option) (*yaml.Node, *config.Variables, []config.CallbackDefinition, error) {
+	if path == "-" {
+		return config.LoadReader(os.Stdin, opts...)
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return config.LoadDir(path, opts...)
+	}
+	return config.Load(path, []byte{}, opts...)
+}
+
+// fatalConfigError logs err, listing each problem on its own line if it holds
+// several, and exits.
+func fatalConfigError(msg string, err error) {
+	var errs config.ValidationErrors
+	if errors.As(err, &errs) && len(errs) > 1 {
+		log.Printf("%s: %d problems:", msg, len(errs))
+		for _, e := range errs {
+			log.Printf("  %v", e)
+		}
+		os.Exit(1)
+	}
+	log.Fatalf("%s: %v", msg, err)
+}
+
+// toggleCmd enables or disables a callback in a YAML or JSON config file in place,
+// leaving the rest of the file untouched.
+func toggleCmd(name string, args []string, enabled bool) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to YAML config file, or - to read stdin and write stdout")
+	callback := fs.String("callback", "", "Name of the callback")
+	fs.Parse(args)
+	if *configPath == "" || *callback == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	data, err := readInput(*configPath)
+	if err != nil {
+		log.Fatalf("Error reading config: %v", err)
+	}
+	// Stdin is read as YAML; files are read in the format implied by their extension.
+	format := config.FormatYAML
+	if *configPath != "-" {
+		format = config.FormatFromPath(*configPath)
+	}
+	updated, err := config.SetCallbackEnabled(data, "callbacks", *callback, enabled, config.WithFormat(format))
+	if err != nil {
+		log.Fatalf("Error updating config: %v", err)
+	}
+	if *configPath == "-" {
+		if _, err := os.Stdout.Write(updated); err != nil {
+			log.Fatalf("Error writing updated config: %v", err)
+		}
+		return
+	}
+	if err := os.WriteFile(*configPath, updated, 0644); err != nil {
+		log.Fatalf("Error writing updated config: %v", err)
+	}
+	fmt.Printf("Callback %q in %q is %sd\n", *callback, *configPath, name)
+}
+
 // copyCmd copies a field from a source YAML file to a destination YAML file based on dot-notation paths.
 func copyCmd(args []string) {
 	fs := flag.NewFlagSet("copy", flag.ExitOnError)
-	srcFile := fs.String("srcfile", "", "Source YAML file")
+	srcFile := fs.String("srcfile", "", "Source YAML file, or - for stdin")
 	srcPath := fs.String("srcpath", "", "Dot-notation path in source YAML")
-	dstFile := fs.String("dstfile", "", "Destination YAML file")
+	dstFile := fs.String("dstfile", "", "Destination YAML file, or - to read stdin and write stdout")
 	dstPath := fs.String("dstpath", "", "Dot-notation path in destination YAML")
 	fs.Parse(args)
 	if *srcFile == "" || *srcPath == "" || *dstFile == "" || *dstPath == "" {
 		fs.Usage()
 		os.Exit(1)
 	}
+	if *srcFile == "-" && *dstFile == "-" {
+		log.Fatalf("Only one of -srcfile and -dstfile can read from stdin")
+	}
 
 	// Load the source YAML file.
-	srcBytes, err := os.ReadFile(*srcFile)
+	srcBytes, err := readInput(*srcFile)
 	if err != nil {
 		log.Fatalf("Error reading source file: %v", err)
 	}
@@ -92,7 +210,7 @@ func copyCmd(args []string) {
 	}
 
 	// Load the destination YAML file.
-	dstBytes, err := os.ReadFile(*dstFile)
+	dstBytes, err := readInput(*dstFile)
 	if err != nil {
 		log.Fatalf("Error reading destination file: %v", err)
 	}
@@ -111,9 +229,23 @@ func copyCmd(args []string) {
 	if err != nil {
 		log.Fatalf("Error encoding updated destination YAML: %v", err)
 	}
+	if *dstFile == "-" {
+		if _, err := os.Stdout.Write(updatedBytes); err != nil {
+			log.Fatalf("Error writing updated destination YAML: %v", err)
+		}
+		return
+	}
 	if err := os.WriteFile(*dstFile, updatedBytes, 0644); err != nil {
 		log.Fatalf("Error writing updated destination file: %v", err)
 	}
 
 	fmt.Printf("Successfully copied field %q from %q to field %q in %q\n", *srcPath, *srcFile, *dstPath, *dstFile)
 }
+
+// readInput reads the named file, or stdin if name is "-".
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
@@ -1,8 +1,16 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/dropsite-ai/yamledit"
 	"gopkg.in/yaml.v3"
@@ -23,158 +31,610 @@ type CallbackDefinition struct {
 	Timing    string         `yaml:"timing"` // expected to be "pre" or "post"
 	Target    CallbackTarget `yaml:"target"`
 	Endpoints []string       `yaml:"endpoints"`
+	Secret    string         `yaml:"secret,omitempty"` // optional key in variables.secrets used to sign payloads
+
+	// Optional delivery settings, see delivery.go.
+	Timeout   time.Duration `yaml:"timeout,omitempty"`    // per attempt, e.g. "5s"
+	Retries   int           `yaml:"retries,omitempty"`    // attempts after the first one
+	Backoff   time.Duration `yaml:"backoff,omitempty"`    // delay before the first retry, doubled for each further one
+	OnFailure string        `yaml:"on_failure,omitempty"` // "ignore" or "abort"
+
+	Enabled  *bool  `yaml:"enabled,omitempty"`  // nil means enabled, see IsEnabled
+	Priority int    `yaml:"priority,omitempty"` // callbacks with a higher priority run first
+	When     string `yaml:"when,omitempty"`     // optional filter expression, see Filter
 }
 
 // CallbackTarget represents a callback's target.
 type CallbackTarget struct {
-	Type string `yaml:"type"` // expected to be "file" or "directory"
+	Type string `yaml:"type"` // expected to be "file", "directory", "glob" or "regex"
 	Path string `yaml:"path"`
 }
 
 // ProcessCallbacks accepts a YAML node and a prefix indicating where an array of CallbackDefinition structs
-// is located. It reads and validates the definitions and returns them.
-// If the section is missing, an empty slice is returned.
-func ProcessCallbacks(doc *yaml.Node, prefix string, vars *Variables) ([]CallbackDefinition, error) {
-	var callbacks []CallbackDefinition
+// is located. It reads and validates the definitions and returns them. ${section.key} references in
+// target paths are resolved against vars, which may be nil. If the section is missing or null, an empty
+// slice is returned; if it is not a sequence of callbacks, an error wrapping
+// ErrMalformedSection is. Callback names must be unique. Callbacks are returned by
+// decreasing priority, callbacks with the same priority in the order they are defined.
+// Disabled callbacks are validated and returned too. Every invalid callback is
+// reported in the returned ValidationErrors. WithStrict and WithEvents apply; other
+// options are ignored.
+func ProcessCallbacks(doc *yaml.Node, prefix string, vars *Variables, opts ...Option) ([]CallbackDefinition, error) {
+	return processCallbacks(doc, prefix, vars, nil, newOptions(opts))
+}
 
-	// Attempt to read the callbacks slice at the given prefix.
-	if err := yamledit.ReadNode(doc, prefix, &callbacks); err != nil {
+// processCallbacks implements ProcessCallbacks, naming the source file of invalid callbacks.
+func processCallbacks(doc *yaml.Node, prefix string, vars *Variables, src sources, o *options) ([]CallbackDefinition, error) {
+	if vars == nil {
+		vars = &Variables{}
+	}
+	// Read the callbacks sequence at the given prefix.
+	callbacksNode, err := lookupSection(doc, prefix, src)
+	if err != nil {
+		return nil, err
+	}
+	if callbacksNode == nil {
 		// If the section doesn't exist, return an empty slice without error.
 		return []CallbackDefinition{}, nil
 	}
+	if callbacksNode.Kind != yaml.SequenceNode {
+		return nil, malformedSection(prefix, callbacksNode, "a sequence of callbacks", src)
+	}
 
-	// Validate each callback.
-	for _, cb := range callbacks {
+	// Decode each callback, reporting the ones with the wrong shape.
+	var errs ValidationErrors
+	callbacks := make([]CallbackDefinition, 0, len(callbacksNode.Content))
+	items := make([]*yaml.Node, 0, len(callbacksNode.Content))
+	for _, item := range callbacksNode.Content {
+		var cb CallbackDefinition
+		if o.strict {
+			errs.add(checkCallbackFields(item, prefix, src))
+		}
+		if err := item.Decode(&cb); err != nil {
+			name := ""
+			if nameNode := mappingValue(item, "name"); nameNode != nil {
+				name = nameNode.Value
+			}
+			errs.add((&FieldError{Section: prefix, Key: name, Reason: "malformed callback",
+				Kind: ErrMalformedSection, Err: decodeError(err), callback: true}).at(item, src))
+			continue
+		}
+		callbacks = append(callbacks, cb)
+		items = append(items, item)
+	}
+
+	// Validate each callback, collecting every problem. Each problem is reported at
+	// the position of the offending field, or of the callback if the field is missing.
+	names := make(map[string]*yaml.Node)
+	for i, cb := range callbacks {
+		item := items[i]
+		field := func(fields ...string) *yaml.Node {
+			node := item
+			for _, f := range fields {
+				if node = mappingValue(node, f); node == nil {
+					return item
+				}
+			}
+			return node
+		}
+		fail := func(node *yaml.Node, reason, value string, kind, err error) {
+			errs.add((&FieldError{Section: prefix, Key: cb.Name, Value: value, Reason: reason,
+				Kind: kind, Err: err, callback: true}).at(node, src))
+		}
+		// Names identify callbacks, so they must be unique.
+		if cb.Name != "" {
+			if first, ok := names[cb.Name]; ok {
+				fail(field("name"), "duplicate callback name", cb.Name, ErrDuplicateCallback,
+					fmt.Errorf("already defined%s", src.in(first)))
+			} else {
+				names[cb.Name] = field("name")
+			}
+		}
 		if cb.Timing != "pre" && cb.Timing != "post" {
-			return nil, fmt.Errorf("invalid timing for callback %q: %q", cb.Name, cb.Timing)
+			fail(field("timing"), "invalid timing", cb.Timing, ErrInvalidTiming, nil)
+		}
+		if !slices.Contains(targetTypes, cb.Target.Type) {
+			fail(field("target", "type"), "invalid target type", cb.Target.Type, ErrInvalidTargetType, nil)
+		}
+		// Resolve ${section.key} references in the target path, then check glob and
+		// regex patterns.
+		path, err := vars.Interpolate(cb.Target.Path)
+		if err != nil {
+			fail(field("target", "path"), "invalid target path", cb.Target.Path, nil, err)
+		} else if _, err := compileTarget(CallbackTarget{Type: cb.Target.Type, Path: path}); err != nil {
+			fail(field("target", "path"), "invalid target pattern", path, ErrInvalidPattern, err)
+		}
+		callbacks[i].Target.Path = path
+		// Validate each event against the catalog given by WithEvents.
+		if o.events != nil {
+			eventsNode := field("events")
+			for j, event := range cb.Events {
+				if !o.events.Valid(event) {
+					node := eventsNode
+					if node.Kind == yaml.SequenceNode && j < len(node.Content) {
+						node = node.Content[j]
+					}
+					errs.add((&FieldError{Section: prefix, Key: cb.Name, Value: event, Reason: "unknown event",
+						Kind: ErrUnknownEvent, Suggestion: suggest(event, o.events.Events()), callback: true}).at(node, src))
+				}
+			}
+		}
+		// Validate that the signing secret exists in the provided Variables map.
+		if cb.Secret != "" {
+			if _, exists := vars.Secrets[cb.Secret]; !exists {
+				fail(field("secret"), "unknown secret key", cb.Secret, ErrUnknownSecret, nil)
+			}
 		}
-		if cb.Target.Type != "file" && cb.Target.Type != "directory" {
-			return nil, fmt.Errorf("invalid target type for callback %q: %q", cb.Name, cb.Target.Type)
+		// Check the filter expression.
+		if cb.When != "" {
+			if _, err := CompileFilter(cb.When); err != nil {
+				fail(field("when"), "invalid when expression", cb.When, ErrInvalidFilter, err)
+			}
+		}
+		// Validate the delivery settings.
+		for _, p := range checkDelivery(cb) {
+			fail(field(p.field), "invalid "+p.field, p.value, ErrInvalidDelivery, p.err)
 		}
 		// Validate that each endpoint key exists in the provided Variables map.
-		for _, epKey := range cb.Endpoints {
+		endpointsNode := field("endpoints")
+		for j, epKey := range cb.Endpoints {
 			if _, exists := vars.Endpoints[epKey]; !exists {
-				return nil, fmt.Errorf("callback %q refers to unknown endpoint key %q", cb.Name, epKey)
+				node := endpointsNode
+				if node.Kind == yaml.SequenceNode && j < len(node.Content) {
+					node = node.Content[j]
+				}
+				fail(node, "unknown endpoint key", epKey, ErrUnknownEndpoint, nil)
 			}
 		}
 	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
 
+	sortCallbacks(callbacks)
 	return callbacks, nil
 }
 
 // ProcessVariables accepts a YAML node and a prefix (e.g. "variables" or "custom") indicating
-// where the maps are located. It processes each section and returns a new Variables struct without
-// modifying the original YAML node.
-func ProcessVariables(doc *yaml.Node, prefix string) (*Variables, error) {
+// where the maps are located. It processes each section and returns a new Variables struct.
+// Values may be overridden by environment variables named after their key (see EnvVarName);
+// overrides and generated secrets are written back into the YAML node.
+// Values may reference other variables as ${section.key}, e.g. "${paths.root}/data";
+// references are resolved before endpoints and users are validated and paths are expanded,
+// and $${ stands for a literal ${.
+// Missing or null sections are left nil, while sections that are not mappings of strings
+// are reported as errors wrapping ErrMalformedSection. Every invalid value is reported
+// in the returned ValidationErrors. WithStrict applies; other options are ignored.
+func ProcessVariables(doc *yaml.Node, prefix string, opts ...Option) (*Variables, error) {
+	vars, err := processVariables(doc, prefix, nil, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// processVariables implements ProcessVariables, naming the source file of invalid values.
+// The Variables are returned even when some values are invalid, so callbacks can still
+// be checked against them.
+func processVariables(doc *yaml.Node, prefix string, src sources, o *options) (*Variables, error) {
 	var vars Variables
+	var errs ValidationErrors
 
-	// Process endpoints: validate each URL.
-	endpointsPath := prefix + ".endpoints"
-	if err := yamledit.ReadNode(doc, endpointsPath, &vars.Endpoints); err == nil {
-		for key, endpoint := range vars.Endpoints {
-			if err := validateURL(endpoint); err != nil {
-				return nil, fmt.Errorf("invalid endpoint for %q: %v", key, err)
-			}
-		}
+	// Read the raw sections; missing sections are left nil, and sections with the
+	// wrong shape are reported and skipped.
+	root, err := lookupSection(doc, prefix, src)
+	if err == nil && root != nil && root.Kind != yaml.MappingNode {
+		err = malformedSection(prefix, root, "a mapping", src)
+	}
+	if err != nil {
+		errs.add(err)
+		root = nil
+	}
+	if o.strict {
+		errs.add(checkUnknownKeys(root, variableSections, prefix, "", "unknown section", src))
+	}
+	var endpointsNode, usersNode, pathsNode, secretsNode *yaml.Node
+	vars.Endpoints, endpointsNode, err = readSection(root, prefix, "endpoints", src)
+	errs.add(err)
+	vars.Users, usersNode, err = readSection(root, prefix, "users", src)
+	errs.add(err)
+	vars.Paths, pathsNode, err = readSection(root, prefix, "paths", src)
+	errs.add(err)
+	vars.Secrets, secretsNode, err = readSection(root, prefix, "secrets", src)
+	errs.add(err)
+	nodes := map[string]*yaml.Node{"endpoints": endpointsNode, "secrets": secretsNode, "users": usersNode, "paths": pathsNode}
+	fail := func(section, key, value, reason string, kind, err error) {
+		errs.add((&FieldError{Section: prefix + "." + section, Key: key, Value: value, Reason: reason,
+			Kind: kind, Err: err}).at(mappingValue(nodes[section], key), src))
 	}
 
 	// Process secrets: generate a secret if the value is empty, and update the YAML node.
-	secretsPath := prefix + ".secrets"
-	var secretsMap map[string]string
-	var secretsNode yaml.Node
-	// Read both the mapping into a Go map and also keep the YAML node.
-	if err := yamledit.ReadNode(doc, secretsPath, &secretsMap); err == nil {
-		// Retrieve the YAML node corresponding to the secrets map.
-		if err := yamledit.ReadNode(doc, secretsPath, &secretsNode); err != nil {
-			return nil, err
-		}
-		// Process the mapping and update the YAML node.
+	if secretsNode != nil {
 		// YAML mapping nodes have key/value pairs as sequential elements.
-		for i := 0; i < len(secretsNode.Content); i += 2 {
+		for i := 0; i+1 < len(secretsNode.Content); i += 2 {
 			keyNode := secretsNode.Content[i]
 			valueNode := secretsNode.Content[i+1]
 			// Check if the secret is empty.
 			if valueNode.Value == "" {
 				newSecret, err := generateJWTSecret()
 				if err != nil {
-					return nil, fmt.Errorf("generating secret for %q: %w", keyNode.Value, err)
+					fail("secrets", keyNode.Value, "", "generating secret", nil, err)
+					continue
 				}
-				// Update the YAML node value.
+				// Update the YAML node value and the Go map.
 				valueNode.Value = newSecret
-				// Also update the Go map.
-				secretsMap[keyNode.Value] = newSecret
+				vars.Secrets[keyNode.Value] = newSecret
 			}
 		}
-		// Assign the modified map to your variables struct.
-		vars.Secrets = secretsMap
 	}
 
-	// Process users: validate each username.
-	usersPath := prefix + ".users"
-	if err := yamledit.ReadNode(doc, usersPath, &vars.Users); err == nil {
-		for key, username := range vars.Users {
-			if err := validateUsername(username); err != nil {
-				return nil, fmt.Errorf("invalid username for %q: %v", key, err)
+	// Resolve ${section.key} references between all sections. Values that cannot be
+	// resolved are reported once and not validated further.
+	sections := vars.sections()
+	in := newInterpolator(sections)
+	unresolved := make(map[string]bool)
+	for _, section := range variableSections {
+		values := sections[section]
+		for _, key := range sortedKeys(values) {
+			resolved, err := in.resolve(section + "." + key)
+			if err != nil {
+				fail(section, key, values[key], "unresolved reference", nil, err)
+				unresolved[section+"."+key] = true
+				continue
 			}
+			values[key] = resolved
+		}
+	}
+
+	// Process endpoints: validate each URL.
+	for _, key := range sortedKeys(vars.Endpoints) {
+		if unresolved["endpoints."+key] {
+			continue
+		}
+		if err := validateURL(vars.Endpoints[key]); err != nil {
+			fail("endpoints", key, vars.Endpoints[key], "invalid endpoint", ErrInvalidURL, err)
+		}
+	}
+
+	// Process users: validate each username.
+	for _, key := range sortedKeys(vars.Users) {
+		if unresolved["users."+key] {
+			continue
+		}
+		if err := validateUsername(vars.Users[key]); err != nil {
+			fail("users", key, vars.Users[key], "invalid username", ErrInvalidUsername, err)
 		}
 	}
 
 	// Process paths: expand "~" to the user's home directory.
-	pathsPath := prefix + ".paths"
-	if err := yamledit.ReadNode(doc, pathsPath, &vars.Paths); err == nil {
-		for key, p := range vars.Paths {
-			expanded, err := ExpandPath(p)
-			if err != nil {
-				return nil, fmt.Errorf("expanding path for %q: %w", key, err)
-			}
-			vars.Paths[key] = expanded
+	for _, key := range sortedKeys(vars.Paths) {
+		if unresolved["paths."+key] {
+			continue
 		}
+		expanded, err := ExpandPath(vars.Paths[key])
+		if err != nil {
+			fail("paths", key, vars.Paths[key], "expanding path", ErrInvalidPath, err)
+			continue
+		}
+		vars.Paths[key] = expanded
+	}
+
+	return &vars, errs.err()
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	return &vars, nil
+// variableSections lists the subsections of the variables section, in processing order.
+var variableSections = []string{"endpoints", "secrets", "users", "paths"}
+
+// checkCallbackFields reports the keys of a callback, and of its target, that are
+// not fields of CallbackDefinition or CallbackTarget.
+func checkCallbackFields(item *yaml.Node, prefix string, src sources) error {
+	name := ""
+	if nameNode := mappingValue(item, "name"); nameNode != nil {
+		name = nameNode.Value
+	}
+	var errs ValidationErrors
+	errs.add(checkUnknownKeys(item, yamlFieldNames(CallbackDefinition{}), prefix, name, "unknown field", src))
+	errs.add(checkUnknownKeys(resolveAlias(mappingValue(item, "target")), yamlFieldNames(CallbackTarget{}), prefix, name, "unknown target field", src))
+	return errs.err()
 }
 
-// Load opens the YAML file at the given path, or if the file is not found,
-// uses the provided defaultYAML string. It then parses the content into a document node,
-// processes variables and callbacks, and returns the document, Variables, and callbacks.
-func Load(path string, defaultYAML []byte) (*yaml.Node, *Variables, []CallbackDefinition, error) {
+// checkUnknownKeys reports every key of the mapping node that is not in known,
+// suggesting the nearest known key. If callback is not empty the errors name that
+// callback, otherwise the unknown key itself.
+func checkUnknownKeys(mapping *yaml.Node, known []string, section, callback, reason string, src sources) error {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	var errs ValidationErrors
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode := mapping.Content[i]
+		if slices.Contains(known, keyNode.Value) {
+			continue
+		}
+		fieldErr := &FieldError{Section: section, Key: callback, Value: keyNode.Value, Reason: reason,
+			Kind: ErrUnknownField, Suggestion: suggest(keyNode.Value, known), callback: callback != ""}
+		if callback == "" {
+			fieldErr.Key = keyNode.Value
+		}
+		errs.add(fieldErr.at(keyNode, src))
+	}
+	return errs.err()
+}
+
+// yamlFieldNames returns the yaml names of the fields of the struct v.
+func yamlFieldNames(v interface{}) []string {
+	var names []string
+	for _, info := range structFields(reflect.TypeOf(v)) {
+		if info != nil {
+			names = append(names, info.name)
+		}
+	}
+	return names
+}
+
+// readSection applies environment overrides to the mapping under key section of
+// parent (the prefix node), decodes it and returns it with the mapping node. A missing
+// or null section returns nils; a section that is not a mapping of strings is an error.
+func readSection(parent *yaml.Node, prefix, section string, src sources) (map[string]string, *yaml.Node, error) {
+	node := resolveAlias(mappingValue(parent, section))
+	if isNull(node) {
+		return nil, nil, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, nil, malformedSection(prefix+"."+section, node, "a mapping", src)
+	}
+	applyEnvOverrides(node, prefix, section)
+	var values map[string]string
+	if err := node.Decode(&values); err != nil {
+		return nil, nil, (&FieldError{Section: prefix, Key: section, Reason: "malformed section",
+			Kind: ErrMalformedSection, Err: decodeError(err)}).at(node, src)
+	}
+	return values, node, nil
+}
+
+// lookupSection returns the node at the dot-notation path of doc, or nil if the path
+// does not exist or holds null. Unlike yamledit.ReadNode, it reports a parent that is
+// not a mapping as a malformed section instead of treating the path as missing.
+func lookupSection(doc *yaml.Node, path string, src sources) (*yaml.Node, error) {
+	node := documentRoot(doc)
+	parent := ""
+	for _, key := range strings.Split(path, ".") {
+		node = resolveAlias(node)
+		if isNull(node) {
+			return nil, nil
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, malformedSection(parent, node, "a mapping", src)
+		}
+		node = mappingValue(node, key)
+		parent = joinPath(parent, key, false)
+	}
+	node = resolveAlias(node)
+	if isNull(node) {
+		return nil, nil
+	}
+	return node, nil
+}
+
+// malformedSection reports that the section at the dot-notation path holds node
+// instead of the wanted kind of node.
+func malformedSection(path string, node *yaml.Node, want string, src sources) error {
+	section, key := "", path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		section, key = path[:i], path[i+1:]
+	}
+	got := "a scalar"
+	switch node.Kind {
+	case yaml.MappingNode:
+		got = "a mapping"
+	case yaml.SequenceNode:
+		got = "a sequence"
+	}
+	return (&FieldError{Section: section, Key: key, Value: node.Value, Reason: "malformed section",
+		Kind: ErrMalformedSection, Err: fmt.Errorf("expected %s, got %s", want, got)}).at(node, src)
+}
+
+// isNull reports whether node is missing or holds null.
+func isNull(node *yaml.Node) bool {
+	return node == nil || (node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null")
+}
+
+// decodeError flattens the per-field messages of a *yaml.TypeError onto one line.
+func decodeError(err error) error {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		return errors.New(strings.Join(typeErr.Errors, "; "))
+	}
+	return err
+}
+
+// defaultSource names the default YAML passed to Load in error messages.
+const defaultSource = "default configuration"
+
+// Load opens the YAML file at the given path (or a JSON or TOML file, by extension
+// or WithFormat), or if the file is not found, uses the provided defaultYAML string.
+// It then parses the content into a document node, resolves the files listed under
+// a top-level include key (relative to path), processes variables and callbacks, and
+// returns the merged document, Variables, and callbacks. With WithDefaultsMerge,
+// defaultYAML is also merged underneath an existing file; keys missing from the file
+// are filled in from the defaults and Save persists them.
+func Load(path string, defaultYAML []byte, opts ...Option) (*yaml.Node, *Variables, []CallbackDefinition, error) {
+	o := newOptions(opts)
+	doc, m, err := loadFile(path, defaultYAML, o)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return process(doc, m.src, o)
+}
+
+// loadFile implements Load up to processing, returning the merged document and
+// the merger that records where its nodes came from.
+func loadFile(path string, defaultYAML []byte, o *options) (*yaml.Node, *merger, error) {
+	source := path
+	format := o.format.resolve(path)
+	usedDefaults := false
 	yamlBytes, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) && len(defaultYAML) != 0 {
 			yamlBytes = defaultYAML
+			source = defaultSource
+			format = FormatYAML
+			usedDefaults = true
 		} else {
-			return nil, nil, nil, fmt.Errorf("reading YAML file: %w", err)
+			return nil, nil, fmt.Errorf("reading config file: %w", err)
 		}
 	}
 
-	doc, err := yamledit.Parse(yamlBytes)
+	doc, m, err := loadDocument(nil, path, source, yamlBytes, format, o)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("parsing YAML: %w", err)
+		return nil, nil, err
 	}
 
-	// Process variables under the "variables" key.
-	vars, err := ProcessVariables(doc, "variables")
+	// Fill in keys missing from the file from the defaults.
+	if o.mergeDefaults && !usedDefaults && len(defaultYAML) != 0 {
+		defaults, err := yamledit.Parse(defaultYAML)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing default YAML: %w", err)
+		}
+		m.src.record(defaults, defaultSource)
+		doc = m.merge(defaults, doc)
+	}
+
+	return doc, m, nil
+}
+
+// LoadReader reads a configuration from r, such as os.Stdin, in the format given by
+// WithFormat (YAML by default). Included files are resolved relative to the working
+// directory. Variables and callbacks are processed as with Load.
+func LoadReader(r io.Reader, opts ...Option) (*yaml.Node, *Variables, []CallbackDefinition, error) {
+	o := newOptions(opts)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading config: %w", err)
+	}
+	name := "<input>"
+	if r == os.Stdin {
+		name = "<stdin>"
+	}
+	format := o.format
+	if format == FormatAuto {
+		format = FormatYAML
+	}
+	doc, m, err := loadDocument(nil, name, name, data, format, o)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return process(doc, m.src, o)
+}
+
+// LoadFS reads the configuration file name from fsys, such as an embed.FS, with its
+// format detected from the extension unless WithFormat is given. Included files are
+// resolved relative to name within fsys. Variables and callbacks are processed as with Load.
+func LoadFS(fsys fs.FS, name string, opts ...Option) (*yaml.Node, *Variables, []CallbackDefinition, error) {
+	o := newOptions(opts)
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading config file: %w", err)
+	}
+	doc, m, err := loadDocument(fsys, name, name, data, o.format.resolve(name), o)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return process(doc, m.src, o)
+}
+
+// loadDocument parses data read from path in fsys (or the OS file system if fsys is nil)
+// and merges the files it includes underneath it. source names the data in error messages.
+func loadDocument(fsys fs.FS, path, source string, data []byte, format Format, o *options) (*yaml.Node, *merger, error) {
+	doc, err := parse(data, format)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("processing variables: %w", err)
+		return nil, nil, fmt.Errorf("parsing %v: %w", format, err)
 	}
 
-	// Process callbacks under the "callbacks" key.
-	callbacks, err := ProcessCallbacks(doc, "callbacks", vars)
+	// Merge included files underneath the document.
+	m := &merger{src: sources{}, sequences: o.sequences}
+	m.src.record(doc, source)
+	doc, err = resolveIncludes(fsys, doc, path, m, nil)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("processing callbacks: %w", err)
+		return nil, nil, fmt.Errorf("resolving includes: %w", err)
+	}
+	return doc, m, nil
+}
+
+// LoadLayers loads each YAML file in paths (resolving its includes) and deep-merges
+// them in order, so later files override earlier ones: mappings are merged key by key
+// and sequences are replaced unless WithSequenceMerge(AppendSequences) is given.
+// Variables and callbacks are processed on the merged document, which is returned
+// along with the Variables and callbacks.
+func LoadLayers(paths []string, opts ...Option) (*yaml.Node, *Variables, []CallbackDefinition, error) {
+	if len(paths) == 0 {
+		return nil, nil, nil, fmt.Errorf("no layers to load")
+	}
+	o := newOptions(opts)
+	m := &merger{src: sources{}, sequences: o.sequences}
+	var doc *yaml.Node
+	for _, path := range paths {
+		layer, err := parseFile(nil, path, m, nil)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("loading layer %s: %w", path, err)
+		}
+		doc = m.merge(doc, layer)
+	}
+
+	return process(doc, m.src, o)
+}
+
+// process validates a loaded document against the schemas given by WithSchema and
+// processes its variables and callbacks. Problems in the variables and callbacks are
+// collected into a single ValidationErrors.
+func process(doc *yaml.Node, src sources, o *options) (*yaml.Node, *Variables, []CallbackDefinition, error) {
+	// Apply environment overrides first, so the schemas check the effective values.
+	applyVariableOverrides(doc, "variables")
+	for _, schema := range o.schemas {
+		if err := schema.check(doc, src); err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
+	// Process variables under the "variables" key.
+	var errs ValidationErrors
+	vars, err := processVariables(doc, "variables", src, o)
+	errs.add(err)
+
+	// Process callbacks under the "callbacks" key, checking them against the
+	// variables even if some of those were invalid.
+	callbacks, err := processCallbacks(doc, "callbacks", vars, src, o)
+	errs.add(err)
+
+	if err := errs.err(); err != nil {
+		return nil, nil, nil, err
+	}
 	return doc, vars, callbacks, nil
 }
 
 // Save encodes the provided YAML document and writes it to the specified path.
-func Save(path string, doc *yaml.Node) error {
-	yamlBytes, err := yamledit.Encode(doc)
+// The document is written as JSON or TOML if the path has a ".json" or ".toml"
+// extension, or in the format given by WithFormat. Save writes doc as it is: a
+// document that Load, LoadDir or LoadLayers merged from several files is written as
+// one flattened file, including the values that came from the other files.
+func Save(path string, doc *yaml.Node, opts ...Option) error {
+	format := newOptions(opts).format.resolve(path)
+	data, err := encode(doc, format)
 	if err != nil {
-		return fmt.Errorf("encoding YAML: %w", err)
+		return fmt.Errorf("encoding %v: %w", format, err)
 	}
-	if err := os.WriteFile(path, yamlBytes, 0644); err != nil {
-		return fmt.Errorf("writing YAML file: %w", err)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing config file: %w", err)
 	}
 	return nil
 }
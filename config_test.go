@@ -1,10 +1,14 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/dropsite-ai/yamledit"
 	"gopkg.in/yaml.v3"
@@ -283,6 +287,37 @@ other:
 			t.Errorf("expected no callbacks, got %d", len(callbacks))
 		}
 	})
+
+	t.Run("Nil variables", func(t *testing.T) {
+		yamlStr := `
+callbacks:
+  - name: "callback1"
+    events: ["event1"]
+    timing: "pre"
+    target:
+      type: "file"
+      path: "some/path"
+    endpoints: []
+  - name: "callback2"
+    events: ["event1"]
+    timing: "post"
+    target:
+      type: "file"
+      path: "${paths.root}/data"
+    endpoints: ["service1"]
+`
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+			t.Fatalf("failed to unmarshal YAML: %v", err)
+		}
+		_, err := ProcessCallbacks(&doc, "callbacks", nil)
+		if !errors.Is(err, ErrUnknownEndpoint) || !errors.Is(err, ErrUnknownReference) {
+			t.Fatalf("expected unknown endpoint and reference errors, got %v", err)
+		}
+		if regexp.MustCompile(`callback1`).MatchString(err.Error()) {
+			t.Errorf("expected callback1 to be valid without variables, got %v", err)
+		}
+	})
 }
 
 func TestSecretsNodeUpdate(t *testing.T) {
@@ -491,3 +526,416 @@ variables:
 		t.Errorf("expected 0 callbacks, got %d", len(callbacks))
 	}
 }
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.yaml": `
+variables:
+  endpoints:
+    service1: "http://example.com"
+    service2: "http://example.org"
+callbacks:
+  - name: "callback1"
+    events: ["event1"]
+    timing: "pre"
+    target:
+      type: "file"
+      path: "some/path"
+    endpoints: ["service1"]
+`,
+		"prod.yaml": `
+variables:
+  endpoints:
+    service1: "https://prod.example.com"
+callbacks:
+  - name: "callback2"
+    events: ["event2"]
+    timing: "post"
+    target:
+      type: "directory"
+      path: "another/path"
+    endpoints: ["service2"]
+`,
+	})
+	layers := []string{filepath.Join(dir, "base.yaml"), filepath.Join(dir, "prod.yaml")}
+
+	t.Run("Later layers override earlier ones", func(t *testing.T) {
+		_, vars, callbacks, err := LoadLayers(layers)
+		if err != nil {
+			t.Fatalf("LoadLayers returned error: %v", err)
+		}
+		if vars.Endpoints["service1"] != "https://prod.example.com" {
+			t.Errorf("expected service1 from prod.yaml, got %q", vars.Endpoints["service1"])
+		}
+		if vars.Endpoints["service2"] != "http://example.org" {
+			t.Errorf("expected service2 from base.yaml, got %q", vars.Endpoints["service2"])
+		}
+		if len(callbacks) != 1 || callbacks[0].Name != "callback2" {
+			t.Errorf("expected callbacks to be replaced by prod.yaml, got %+v", callbacks)
+		}
+	})
+
+	t.Run("Append sequences", func(t *testing.T) {
+		_, _, callbacks, err := LoadLayers(layers, WithSequenceMerge(AppendSequences))
+		if err != nil {
+			t.Fatalf("LoadLayers returned error: %v", err)
+		}
+		if len(callbacks) != 2 || callbacks[0].Name != "callback1" || callbacks[1].Name != "callback2" {
+			t.Errorf("expected callbacks from both layers in order, got %+v", callbacks)
+		}
+	})
+
+	t.Run("Missing layer", func(t *testing.T) {
+		_, _, _, err := LoadLayers(append(layers, filepath.Join(dir, "missing.yaml")))
+		if err == nil {
+			t.Fatal("expected error due to missing layer, got nil")
+		}
+	})
+}
+
+func TestLoadWithDefaultsMerge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": `# Main configuration.
+variables:
+  endpoints:
+    # The primary service.
+    service1: "http://override.example.com"
+  secrets:
+    secret1: "existingsecret"
+`,
+	})
+	defaultYAML := []byte(`
+variables:
+  endpoints:
+    service1: "http://example.com"
+    service2: "http://example.org"
+  secrets:
+    secret1: ""
+    secret2: ""
+`)
+
+	t.Run("Defaults are ignored without the option", func(t *testing.T) {
+		_, vars, _, err := Load(path, defaultYAML)
+		if err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+		if _, ok := vars.Endpoints["service2"]; ok {
+			t.Errorf("expected service2 to be absent without WithDefaultsMerge")
+		}
+	})
+
+	t.Run("Missing keys are filled in and saved", func(t *testing.T) {
+		doc, vars, _, err := Load(path, defaultYAML, WithDefaultsMerge())
+		if err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+		if vars.Endpoints["service1"] != "http://override.example.com" {
+			t.Errorf("expected service1 from the file, got %q", vars.Endpoints["service1"])
+		}
+		if vars.Endpoints["service2"] != "http://example.org" {
+			t.Errorf("expected service2 from the defaults, got %q", vars.Endpoints["service2"])
+		}
+		if vars.Secrets["secret1"] != "existingsecret" {
+			t.Errorf("expected secret1 to remain 'existingsecret', got %q", vars.Secrets["secret1"])
+		}
+		secret2 := vars.Secrets["secret2"]
+		if len(secret2) != 64 {
+			t.Fatalf("expected secret2 to be generated, got %q", secret2)
+		}
+
+		if err := Save(path, doc); err != nil {
+			t.Fatalf("Save returned error: %v", err)
+		}
+		saved, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read saved file: %v", err)
+		}
+		for _, want := range []string{"# Main configuration.", "# The primary service.", "service2: \"http://example.org\"", secret2} {
+			if !regexp.MustCompile(regexp.QuoteMeta(want)).Match(saved) {
+				t.Errorf("expected saved file to contain %q, got:\n%s", want, saved)
+			}
+		}
+	})
+}
+
+func TestLoadReader(t *testing.T) {
+	r := strings.NewReader(`
+variables:
+  endpoints:
+    service1: "http://example.com"
+  users:
+    user1: "Not Valid"
+`)
+	_, _, _, err := LoadReader(r)
+	if err == nil {
+		t.Fatal("expected error due to invalid username, got nil")
+	}
+	if !regexp.MustCompile(`invalid username for "user1" in <input>`).MatchString(err.Error()) {
+		t.Errorf("expected error message to name the input, got %v", err)
+	}
+
+	_, vars, _, err := LoadReader(strings.NewReader(`{"variables": {"users": {"user1": "root"}}}`), WithFormat(FormatJSON))
+	if err != nil {
+		t.Fatalf("LoadReader returned error: %v", err)
+	}
+	if vars.Users["user1"] != "root" {
+		t.Errorf("expected user1 to be 'root', got %q", vars.Users["user1"])
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/config.yaml": {Data: []byte(`
+include: [shared/endpoints.json]
+variables:
+  users:
+    user1: "root"
+`)},
+		"defaults/shared/endpoints.json": {Data: []byte(`{"variables": {"endpoints": {"service1": "http://example.com"}}}`)},
+	}
+	_, vars, _, err := LoadFS(fsys, "defaults/config.yaml")
+	if err != nil {
+		t.Fatalf("LoadFS returned error: %v", err)
+	}
+	if vars.Users["user1"] != "root" {
+		t.Errorf("expected user1 to be 'root', got %q", vars.Users["user1"])
+	}
+	if vars.Endpoints["service1"] != "http://example.com" {
+		t.Errorf("expected service1 from the included file, got %q", vars.Endpoints["service1"])
+	}
+
+	if _, _, _, err := LoadFS(fsys, "missing.yaml"); err == nil {
+		t.Error("expected error for a missing file, got nil")
+	}
+}
+
+func TestMalformedSections(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"Callbacks mapping", "callbacks:\n  foo: bar\n", `malformed section for "callbacks" at line 2, column 3: expected a sequence of callbacks, got a mapping`},
+		{"Callbacks scalar", "callbacks: none\n", `malformed section for "callbacks" at line 1, column 12: expected a sequence of callbacks, got a scalar`},
+		{"Callback with wrong field type", "callbacks:\n  - name: \"cb\"\n    events: \"write\"\n", `malformed callback for callback "cb" at line 2, column 5: line 3: cannot unmarshal`},
+		{"Callback scalar", "callbacks:\n  - cb\n", `malformed callback for callback "" at line 2, column 5`},
+		{"Variables sequence", "variables: [a, b]\n", `malformed section for "variables" at line 1, column 12: expected a mapping, got a sequence`},
+		{"Endpoints sequence", "variables:\n  endpoints: [a, b]\n", `malformed section for "endpoints" at line 2, column 14: expected a mapping, got a sequence`},
+		{"Nested endpoint", "variables:\n  endpoints:\n    api:\n      url: \"http://example.com\"\n", `malformed section for "endpoints" at line 3, column 5: line 4: cannot unmarshal`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := yamledit.Parse([]byte(c.yaml))
+			if err != nil {
+				t.Fatalf("failed to parse YAML: %v", err)
+			}
+			_, _, _, err = process(doc, nil, newOptions(nil))
+			if !errors.Is(err, ErrMalformedSection) {
+				t.Fatalf("expected ErrMalformedSection, got %v", err)
+			}
+			if !regexp.MustCompile(c.want).MatchString(err.Error()) {
+				t.Errorf("expected error matching %q, got %v", c.want, err)
+			}
+		})
+	}
+
+	t.Run("Missing and null sections are empty", func(t *testing.T) {
+		for _, y := range []string{"other: 1\n", "callbacks:\nvariables:\n  endpoints:\n", "variables: ~\n"} {
+			doc, err := yamledit.Parse([]byte(y))
+			if err != nil {
+				t.Fatalf("failed to parse YAML: %v", err)
+			}
+			_, vars, callbacks, err := process(doc, nil, newOptions(nil))
+			if err != nil {
+				t.Errorf("%q: expected no error, got %v", y, err)
+				continue
+			}
+			if len(callbacks) != 0 || len(vars.Endpoints) != 0 {
+				t.Errorf("%q: expected no callbacks or endpoints, got %v and %v", y, callbacks, vars.Endpoints)
+			}
+		}
+	})
+}
+
+func TestStrict(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config.yaml": `variables:
+  enpoints:
+    api: "http://example.com"
+callbacks:
+  - name: "cb"
+    events: ["write"]
+    timming: "pre"
+    timing: "post"
+    target:
+      type: "file"
+      pth: "/x"
+      path: "/x"
+    endpoints: []
+    extra: true
+`})
+	path := filepath.Join(dir, "config.yaml")
+
+	if _, _, _, err := Load(path, nil); err != nil {
+		t.Fatalf("expected unknown keys to be ignored without WithStrict, got %v", err)
+	}
+
+	_, _, _, err := Load(path, nil, WithStrict())
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %v", err)
+	}
+	if !errors.Is(err, ErrUnknownField) {
+		t.Errorf("expected ErrUnknownField, got %v", err)
+	}
+	for _, want := range []string{
+		`unknown section for "enpoints" in .*config\.yaml:2:3: "enpoints" \(did you mean "endpoints"\?\)`,
+		`unknown field for callback "cb" in .*config\.yaml:7:5: "timming" \(did you mean "timing"\?\)`,
+		`unknown target field for callback "cb" in .*config\.yaml:11:7: "pth" \(did you mean "path"\?\)`,
+		`unknown field for callback "cb" in .*config\.yaml:14:5: "extra"$`,
+	} {
+		if !regexp.MustCompile(`(?m)` + want).MatchString(err.Error()) {
+			t.Errorf("expected error matching %q, got %v", want, err)
+		}
+	}
+	var fieldErr *FieldError
+	if errors.As(errs[1], &fieldErr) && fieldErr.Suggestion != "timing" {
+		t.Errorf("expected suggestion %q, got %q", "timing", fieldErr.Suggestion)
+	}
+}
+
+func TestDuplicateCallbackNames(t *testing.T) {
+	doc, err := yamledit.Parse([]byte(`callbacks:
+  - name: "cb"
+    events: ["write"]
+    timing: "pre"
+    target: {type: "file", path: "/a"}
+    endpoints: []
+  - name: "other"
+    events: ["write"]
+    timing: "pre"
+    target: {type: "file", path: "/b"}
+    endpoints: []
+  - name: "cb"
+    events: ["read"]
+    timing: "post"
+    target: {type: "file", path: "/c"}
+    endpoints: []
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	_, err = ProcessCallbacks(doc, "callbacks", &Variables{})
+	if !errors.Is(err, ErrDuplicateCallback) {
+		t.Fatalf("expected ErrDuplicateCallback, got %v", err)
+	}
+	want := `duplicate callback name for callback "cb" at line 12, column 11: already defined at line 2, column 11`
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestCallbackSecrets(t *testing.T) {
+	doc, err := yamledit.Parse([]byte(`variables:
+  secrets:
+    hook: ""
+callbacks:
+  - name: "signed"
+    events: ["write"]
+    timing: "pre"
+    target: {type: "file", path: "/a"}
+    endpoints: []
+    secret: "hook"
+  - name: "typo"
+    events: ["write"]
+    timing: "pre"
+    target: {type: "file", path: "/b"}
+    endpoints: []
+    secret: "hok"
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	_, _, _, err = process(doc, nil, newOptions(nil))
+	if !errors.Is(err, ErrUnknownSecret) {
+		t.Fatalf("expected ErrUnknownSecret, got %v", err)
+	}
+	if want := `unknown secret key for callback "typo" at line 16, column 13: "hok"`; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestCallbackDelivery(t *testing.T) {
+	doc, err := yamledit.Parse([]byte(`callbacks:
+  - name: "scan"
+    events: ["write"]
+    timing: "pre"
+    target: {type: "file", path: "/a"}
+    endpoints: []
+    timeout: "2s"
+    retries: 3
+    backoff: "200ms"
+    on_failure: "ignore"
+  - name: "notify"
+    events: ["write"]
+    timing: "post"
+    target: {type: "file", path: "/a"}
+    endpoints: []
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	_, _, callbacks, err := process(doc, nil, newOptions(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scan := callbacks[0]
+	if scan.Timeout != 2*time.Second || scan.Retries != 3 || scan.Backoff != 200*time.Millisecond || scan.FailureAction() != OnFailureIgnore {
+		t.Errorf("unexpected delivery settings %+v", scan)
+	}
+	if action := callbacks[1].FailureAction(); action != OnFailureIgnore {
+		t.Errorf("expected post callbacks to ignore failures, got %q", action)
+	}
+	if action := (CallbackDefinition{Timing: "pre"}).FailureAction(); action != OnFailureAbort {
+		t.Errorf("expected pre callbacks to abort by default, got %q", action)
+	}
+
+	doc, err = yamledit.Parse([]byte(`callbacks:
+  - name: "bad"
+    events: ["write"]
+    timing: "post"
+    target: {type: "file", path: "/a"}
+    endpoints: []
+    timeout: "-1s"
+    retries: 11
+    backoff: "2m"
+    on_failure: "abort"
+  - name: "typo"
+    events: ["write"]
+    timing: "pre"
+    target: {type: "file", path: "/a"}
+    endpoints: []
+    on_failure: "skip"
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	_, _, _, err = process(doc, nil, newOptions(nil))
+	if !errors.Is(err, ErrInvalidDelivery) {
+		t.Fatalf("expected ErrInvalidDelivery, got %v", err)
+	}
+	for _, want := range []string{
+		`invalid timeout for callback "bad" at line 7, column 14: must be between 0s and 5m0s`,
+		`invalid retries for callback "bad" at line 8, column 14: must be between 0 and 10`,
+		`invalid backoff for callback "bad" at line 9, column 14: must be between 0s and 1m0s`,
+		`invalid on_failure for callback "bad" at line 10, column 17: post callbacks run after the operation and cannot abort it`,
+		`invalid on_failure for callback "typo" at line 16, column 17: must be "ignore" or "abort"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decode binds the section at the dot-notation path of doc (the whole document
+// if path is empty) to a value of type T, which is usually a struct. Struct fields
+// are named by their yaml tag and may carry two more tags:
+//
+//	default:"8080"          value used when the field is absent, written in YAML
+//	config:"required,url"   comma-separated rules
+//
+// The rules are "required" (the key must be present), "url" and "username"
+// (validated like variables.endpoints and variables.users) and "path" (a leading
+// "~" is expanded like variables.paths). Nested structs, and pointers to structs,
+// are decoded the same way; a nil pointer to a struct with defaults is allocated.
+// A missing section is not an error unless it leaves a required field unset.
+// Missing and invalid values are reported as a *FieldError matching ErrMissingField,
+// ErrInvalidURL, ErrInvalidUsername or ErrInvalidPath with errors.Is.
+func Decode[T any](doc *yaml.Node, path string) (T, error) {
+	var out T
+	node, err := sectionNode(doc, path)
+	if err != nil {
+		return out, err
+	}
+
+	v := reflect.ValueOf(&out).Elem()
+	if err := applyDefaults(v, path); err != nil {
+		return out, err
+	}
+	if node != nil {
+		if err := node.Decode(&out); err != nil {
+			return out, fmt.Errorf("decoding %s: %w", displayPath(path), err)
+		}
+	}
+	if err := checkFields(v, node, path); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// sectionNode returns the node at the dot-notation path of doc, the document
+// root for an empty path, or nil if the path does not exist. A path going through
+// something other than a mapping is an error wrapping ErrMalformedSection.
+func sectionNode(doc *yaml.Node, path string) (*yaml.Node, error) {
+	if doc == nil || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if path == "" {
+		return documentRoot(doc), nil
+	}
+	return lookupSection(doc, path, nil)
+}
+
+// fieldInfo describes how a struct field is bound.
+type fieldInfo struct {
+	name   string
+	inline bool
+	rules  map[string]bool
+}
+
+// structFields returns the bound fields of a struct type, indexed like the type.
+func structFields(t reflect.Type) []*fieldInfo {
+	fields := make([]*fieldInfo, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, flags, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		info := &fieldInfo{name: name, inline: strings.Contains(flags, "inline"), rules: make(map[string]bool)}
+		for _, rule := range strings.Split(f.Tag.Get("config"), ",") {
+			if rule = strings.TrimSpace(rule); rule != "" {
+				info.rules[rule] = true
+			}
+		}
+		fields[i] = info
+	}
+	return fields
+}
+
+// applyDefaults sets every field of the struct v that has a default tag, allocating
+// v first if it is a nil pointer to a struct with defaults.
+func applyDefaults(v reflect.Value, path string) error {
+	if v.Kind() == reflect.Pointer {
+		if !hasDefaults(v.Type().Elem(), nil) {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i, info := range structFields(v.Type()) {
+		if info == nil {
+			continue
+		}
+		field := v.Field(i)
+		fieldPath := joinPath(path, info.name, info.inline)
+		if def, ok := v.Type().Field(i).Tag.Lookup("default"); ok {
+			if err := yaml.Unmarshal([]byte(def), field.Addr().Interface()); err != nil {
+				return fmt.Errorf("invalid default for %s: %w", fieldPath, err)
+			}
+		}
+		if err := applyDefaults(field, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasDefaults reports whether the struct type t, or a struct nested in it, has a
+// field with a default tag. seen guards against recursive types.
+func hasDefaults(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	if seen == nil {
+		seen = make(map[reflect.Type]bool)
+	}
+	seen[t] = true
+	for i, info := range structFields(t) {
+		if info == nil {
+			continue
+		}
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("default"); ok || hasDefaults(f.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFields enforces the config tag rules of the struct v decoded from node,
+// reporting the position of the offending value (or of node, for a missing field).
+func checkFields(v reflect.Value, node *yaml.Node, path string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i, info := range structFields(v.Type()) {
+		if info == nil {
+			continue
+		}
+		field := v.Field(i)
+		fieldPath := joinPath(path, info.name, info.inline)
+		child := node
+		if !info.inline {
+			child = mappingValue(node, info.name)
+		}
+
+		if info.rules["required"] && child == nil {
+			return (&FieldError{Section: path, Key: fieldPath, Reason: "missing required field",
+				Kind: ErrMissingField, Err: fmt.Errorf("no %q key", info.name)}).at(node, nil)
+		}
+		if field.Kind() == reflect.String && field.String() != "" {
+			value := field.String()
+			fail := func(reason string, kind, err error) error {
+				return (&FieldError{Section: path, Key: fieldPath, Value: value, Reason: reason,
+					Kind: kind, Err: err}).at(child, nil)
+			}
+			if info.rules["url"] {
+				if err := validateURL(value); err != nil {
+					return fail("invalid URL", ErrInvalidURL, err)
+				}
+			}
+			if info.rules["username"] {
+				if err := validateUsername(value); err != nil {
+					return fail("invalid username", ErrInvalidUsername, err)
+				}
+			}
+			if info.rules["path"] {
+				expanded, err := ExpandPath(value)
+				if err != nil {
+					return fail("expanding path", ErrInvalidPath, err)
+				}
+				field.SetString(expanded)
+			}
+		}
+		if err := checkFields(field, child, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinPath appends a field name to a dot-notation path; inline fields share
+// their parent's path.
+func joinPath(path, name string, inline bool) string {
+	if inline {
+		return path
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// displayPath returns path, or a placeholder for the document root.
+func displayPath(path string) string {
+	if path == "" {
+		return "document"
+	}
+	return path
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+type testServer struct {
+	Host    string        `yaml:"host" config:"required"`
+	Port    int           `yaml:"port" default:"8080"`
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+	Hook    string        `yaml:"hook" config:"url"`
+	Owner   string        `yaml:"owner" config:"username"`
+	DataDir string        `yaml:"data_dir" config:"path" default:"~/data"`
+	TLS     struct {
+		Enabled bool   `yaml:"enabled" default:"true"`
+		Cert    string `yaml:"cert" config:"path"`
+	} `yaml:"tls"`
+	Limits *struct {
+		Requests int `yaml:"requests" default:"100"`
+		Burst    int `yaml:"burst"`
+	} `yaml:"limits"`
+}
+
+func TestDecode(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("Cannot determine user home directory")
+	}
+
+	t.Run("Defaults, validation and path expansion", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte(`
+server:
+  host: "localhost"
+  timeout: "30s"
+  hook: "http://example.com/hook"
+  owner: "root"
+  tls:
+    cert: "~/cert.pem"
+`))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		server, err := Decode[testServer](doc, "server")
+		if err != nil {
+			t.Fatalf("Decode returned error: %v", err)
+		}
+		if server.Host != "localhost" || server.Port != 8080 || server.Timeout != 30*time.Second {
+			t.Errorf("unexpected values: %+v", server)
+		}
+		if server.DataDir != filepath.Join(home, "data") {
+			t.Errorf("expected default data_dir to be expanded, got %q", server.DataDir)
+		}
+		if !server.TLS.Enabled || server.TLS.Cert != filepath.Join(home, "cert.pem") {
+			t.Errorf("unexpected nested values: %+v", server.TLS)
+		}
+		if server.Limits == nil || server.Limits.Requests != 100 {
+			t.Errorf("expected defaults in an absent pointer struct, got %+v", server.Limits)
+		}
+	})
+
+	t.Run("Defaults in pointer structs", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte("server:\n  host: a\n  limits:\n    burst: 5\n"))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		server, err := Decode[testServer](doc, "server")
+		if err != nil {
+			t.Fatalf("Decode returned error: %v", err)
+		}
+		if server.Limits == nil || server.Limits.Requests != 100 || server.Limits.Burst != 5 {
+			t.Errorf("expected defaults merged with decoded values, got %+v", server.Limits)
+		}
+	})
+
+	cases := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"Missing required field", "server:\n  port: 80\n", `missing required field for "server.host" at line 2, column 3: no "host" key`},
+		{"Missing section with required field", "other: {}\n", `missing required field for "server.host": no "host" key`},
+		{"Invalid URL", "server:\n  host: a\n  hook: not-a-url\n", `invalid URL for "server.hook" at line 3, column 9: invalid URL: "not-a-url"`},
+		{"Invalid username", "server:\n  host: a\n  owner: Root\n", `invalid username for "server.owner" at line 3, column 10: username "Root" is invalid`},
+		{"Wrong type", "server:\n  host: a\n  port: eighty\n", `decoding server`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := yamledit.Parse([]byte(c.yaml))
+			if err != nil {
+				t.Fatalf("failed to parse YAML: %v", err)
+			}
+			_, err = Decode[testServer](doc, "server")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !regexp.MustCompile(c.want).MatchString(err.Error()) {
+				t.Errorf("expected error matching %q, got %v", c.want, err)
+			}
+		})
+	}
+
+	t.Run("Invalid values match their sentinel", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte("server:\n  host: a\n  hook: not-a-url\n"))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		_, err = Decode[testServer](doc, "server")
+		var fieldErr *FieldError
+		if !errors.Is(err, ErrInvalidURL) || !errors.As(err, &fieldErr) || fieldErr.Key != "server.hook" || fieldErr.Line != 3 {
+			t.Errorf("expected a *FieldError matching ErrInvalidURL, got %#v", err)
+		}
+	})
+
+	t.Run("Missing fields match their sentinel", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte("server:\n  port: 80\n"))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		_, err = Decode[testServer](doc, "server")
+		var fieldErr *FieldError
+		if !errors.Is(err, ErrMissingField) || !errors.As(err, &fieldErr) || fieldErr.Key != "server.host" || fieldErr.Line != 2 {
+			t.Errorf("expected a *FieldError matching ErrMissingField, got %#v", err)
+		}
+	})
+
+	t.Run("Malformed parent section", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte("server: [a]\n"))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		if _, err = Decode[map[string]string](doc, "server.tls"); !errors.Is(err, ErrMalformedSection) {
+			t.Errorf("expected ErrMalformedSection, got %v", err)
+		}
+	})
+
+	t.Run("Whole document", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte("name: llmfs\n"))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		out, err := Decode[map[string]string](doc, "")
+		if err != nil {
+			t.Fatalf("Decode returned error: %v", err)
+		}
+		if out["name"] != "llmfs" {
+			t.Errorf("expected name 'llmfs', got %q", out["name"])
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bounds of the delivery settings of a callback.
+const (
+	MaxCallbackTimeout = 5 * time.Minute
+	MaxCallbackRetries = 10
+	MaxCallbackBackoff = time.Minute
+)
+
+// Values of CallbackDefinition.OnFailure.
+const (
+	// OnFailureIgnore lets the operation go ahead when a pre callback fails.
+	OnFailureIgnore = "ignore"
+	// OnFailureAbort vetoes the operation when a pre callback fails.
+	OnFailureAbort = "abort"
+)
+
+// FailureAction returns what happens when the callback cannot be delivered or its
+// endpoint rejects it: OnFailure if set, otherwise OnFailureAbort for pre callbacks
+// and OnFailureIgnore for post callbacks, which run after the operation.
+func (cb CallbackDefinition) FailureAction() string {
+	if cb.OnFailure != "" {
+		return cb.OnFailure
+	}
+	if cb.Timing == "pre" {
+		return OnFailureAbort
+	}
+	return OnFailureIgnore
+}
+
+// deliveryProblem is an invalid delivery setting of a callback.
+type deliveryProblem struct {
+	field string
+	value string
+	err   error
+}
+
+// checkDelivery returns the delivery settings of cb that are out of bounds.
+func checkDelivery(cb CallbackDefinition) []deliveryProblem {
+	var problems []deliveryProblem
+	if cb.Timeout < 0 || cb.Timeout > MaxCallbackTimeout {
+		problems = append(problems, deliveryProblem{"timeout", cb.Timeout.String(),
+			fmt.Errorf("must be between 0s and %v", MaxCallbackTimeout)})
+	}
+	if cb.Retries < 0 || cb.Retries > MaxCallbackRetries {
+		problems = append(problems, deliveryProblem{"retries", fmt.Sprint(cb.Retries),
+			fmt.Errorf("must be between 0 and %d", MaxCallbackRetries)})
+	}
+	if cb.Backoff < 0 || cb.Backoff > MaxCallbackBackoff {
+		problems = append(problems, deliveryProblem{"backoff", cb.Backoff.String(),
+			fmt.Errorf("must be between 0s and %v", MaxCallbackBackoff)})
+	}
+	switch cb.OnFailure {
+	case "", OnFailureIgnore:
+	case OnFailureAbort:
+		if cb.Timing == "post" {
+			problems = append(problems, deliveryProblem{"on_failure", cb.OnFailure,
+				fmt.Errorf("post callbacks run after the operation and cannot abort it")})
+		}
+	default:
+		problems = append(problems, deliveryProblem{"on_failure", cb.OnFailure,
+			fmt.Errorf("must be %q or %q", OnFailureIgnore, OnFailureAbort)})
+	}
+	return problems
+}
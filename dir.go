@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configExtensions lists the file extensions LoadDir picks up.
+var configExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true, ".toml": true}
+
+// LoadDir loads every *.yaml, *.yml, *.json and *.toml file in dir (such as
+// /etc/llmfs/conf.d) in lexical order and merges them into one document before
+// processing variables and callbacks. Sequences such as callbacks are concatenated
+// across files, and a key defined by more than one file is reported as an error
+// naming both files.
+// Options such as WithSchema apply to the merged document; WithSequenceMerge and
+// WithFormat are ignored since fragments are always appended and detected by extension.
+func LoadDir(dir string, opts ...Option) (*yaml.Node, *Variables, []CallbackDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading config directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !configExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	if len(files) == 0 {
+		return nil, nil, nil, fmt.Errorf("no config files in %s", dir)
+	}
+	sort.Strings(files)
+
+	m := &merger{src: sources{}, sequences: AppendSequences, rejectDuplicates: true}
+	var doc *yaml.Node
+	for _, file := range files {
+		fragment, err := parseFile(nil, file, &merger{src: m.src}, nil)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("loading %s: %w", file, err)
+		}
+		doc = m.merge(doc, fragment)
+	}
+	if len(m.duplicates) > 0 {
+		return nil, nil, nil, fmt.Errorf("merging %s: %w", dir, m.duplicates)
+	}
+
+	return process(doc, m.src, newOptions(opts))
+}
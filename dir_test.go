@@ -0,0 +1,81 @@
+package config
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestLoadDir(t *testing.T) {
+	t.Run("Fragments are merged in lexical order", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"10-main.yaml": `
+variables:
+  endpoints:
+    service1: "http://example.com"
+callbacks:
+  - name: "callback1"
+    events: ["event1"]
+    timing: "pre"
+    target:
+      type: "file"
+      path: "some/path"
+    endpoints: ["service1"]
+`,
+			"20-extra.yml": `
+variables:
+  endpoints:
+    service2: "http://example.org"
+callbacks:
+  - name: "callback2"
+    events: ["event2"]
+    timing: "post"
+    target:
+      type: "directory"
+      path: "another/path"
+    endpoints: ["service2"]
+`,
+			"README.txt": "not a config file",
+		})
+		_, vars, callbacks, err := LoadDir(dir)
+		if err != nil {
+			t.Fatalf("LoadDir returned error: %v", err)
+		}
+		if len(vars.Endpoints) != 2 {
+			t.Errorf("expected endpoints from both fragments, got %v", vars.Endpoints)
+		}
+		if len(callbacks) != 2 || callbacks[0].Name != "callback1" || callbacks[1].Name != "callback2" {
+			t.Errorf("expected callbacks from both fragments in order, got %+v", callbacks)
+		}
+	})
+
+	t.Run("Duplicate keys name both files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"a.yaml": `
+variables:
+  endpoints:
+    service1: "http://example.com"
+`,
+			"b.yaml": `
+variables:
+  endpoints:
+    service1: "http://example.org"
+`,
+		})
+		_, _, _, err := LoadDir(dir)
+		if err == nil {
+			t.Fatal("expected error due to duplicate key, got nil")
+		}
+		want := `duplicate key "variables.endpoints.service1" in .*b\.yaml:4:5 \(already defined in .*a\.yaml:4:5\)`
+		if !regexp.MustCompile(want).MatchString(err.Error()) {
+			t.Errorf("expected error message to name both files, got %v", err)
+		}
+	})
+
+	t.Run("Empty directory", func(t *testing.T) {
+		if _, _, _, err := LoadDir(t.TempDir()); err == nil {
+			t.Fatal("expected error for a directory without YAML files, got nil")
+		}
+	})
+}
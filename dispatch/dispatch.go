@@ -0,0 +1,271 @@
+// Package dispatch delivers the callbacks of a processed configuration over HTTP.
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropsite-ai/config"
+)
+
+// Event is the JSON payload posted to the endpoints of a callback.
+type Event struct {
+	Event    string      `json:"event"`
+	Timing   string      `json:"timing"`
+	Path     string      `json:"path"`
+	Callback string      `json:"callback"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// DeliveryError reports that an event could not be delivered to an endpoint, or that
+// the endpoint rejected it with a non-2xx status.
+type DeliveryError struct {
+	Callback   string
+	Endpoint   string // endpoint key in variables.endpoints
+	URL        string
+	StatusCode int    // 0 if no response was received
+	Body       string // start of the response body, usually the reason for a veto
+	Err        error  // transport error, if any
+	Attempts   int    // number of attempts made, including retries
+}
+
+// Error describes the failed delivery.
+func (e *DeliveryError) Error() string {
+	msg := fmt.Sprintf("callback %q to endpoint %q", e.Callback, e.Endpoint)
+	if e.Attempts > 1 {
+		msg = fmt.Sprintf("%s (%d attempts)", msg, e.Attempts)
+	}
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("%s failed: %v", msg, e.Err)
+	case e.Body != "":
+		return fmt.Sprintf("%s returned %d: %s", msg, e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("%s returned %d", msg, e.StatusCode)
+}
+
+// Unwrap returns the transport error.
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultBackoff is the delay before the first retry of callbacks without a backoff.
+const DefaultBackoff = 500 * time.Millisecond
+
+// DefaultTimeout bounds each delivery attempt of callbacks without a timeout.
+const DefaultTimeout = 10 * time.Second
+
+// maxBody limits how much of a response body is kept in a DeliveryError.
+const maxBody = 1024
+
+// Dispatcher posts events to the endpoints of the callbacks matching them.
+type Dispatcher struct {
+	// Client sends the requests. It defaults to a client without a timeout of its
+	// own, since attempts are bounded by the timeout of their callback; a Client
+	// timeout would cut callback timeouts longer than it short.
+	Client *http.Client
+	// OnError, if set, is called with the *DeliveryError of every failed post
+	// callback delivery, and of failed pre callback deliveries that do not abort the
+	// operation. It may be called from several goroutines at once.
+	OnError func(error)
+
+	matcher   *config.Matcher
+	endpoints map[string]string
+	secrets   map[string]string
+	wg        sync.WaitGroup
+}
+
+// New returns a Dispatcher for processed variables and callbacks, such as those
+// returned by config.Load. Payloads of callbacks with a secret are signed with the
+// named variables.secrets value (see Sign).
+func New(vars *config.Variables, callbacks []config.CallbackDefinition) *Dispatcher {
+	d := &Dispatcher{
+		Client:    &http.Client{},
+		matcher:   config.NewMatcher(callbacks),
+		endpoints: make(map[string]string),
+		secrets:   make(map[string]string),
+	}
+	if vars != nil {
+		maps.Copy(d.endpoints, vars.Endpoints)
+		maps.Copy(d.secrets, vars.Secrets)
+	}
+	return d
+}
+
+// Pre delivers event on path to the endpoints of the matching pre callbacks, one at a
+// time by decreasing priority and then in the order they are defined, before the
+// operation runs. A delivery that still fails after its retries, or gets a non-2xx
+// response, vetoes the operation unless the callback sets on_failure to ignore: Pre
+// stops and returns it as a *DeliveryError, and the caller should not perform the
+// operation. Ignored failures are reported to OnError. When expressions of callbacks
+// are evaluated with only the event and path known.
+func (d *Dispatcher) Pre(ctx context.Context, event, path string, data interface{}) error {
+	return d.PreEvent(ctx, config.EventContext{Event: event, Path: path}, data)
+}
+
+// PreEvent is like Pre for the event and path of ec, and evaluates the when
+// expressions of callbacks against ec.
+func (d *Dispatcher) PreEvent(ctx context.Context, ec config.EventContext, data interface{}) error {
+	for _, cb := range d.matcher.MatchContext("pre", ec) {
+		for _, endpoint := range cb.Endpoints {
+			err := d.deliver(ctx, cb, endpoint, ec.Event, ec.Path, data)
+			if err == nil {
+				continue
+			}
+			if cb.FailureAction() == config.OnFailureAbort {
+				return err
+			}
+			if d.OnError != nil {
+				d.OnError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// Post delivers event on path to the endpoints of the matching post callbacks in the
+// background after the operation ran, and returns immediately. Deliveries are not
+// cancelled with ctx; failures are reported to OnError. Wait waits for them. When
+// expressions of callbacks are evaluated with only the event and path known.
+func (d *Dispatcher) Post(ctx context.Context, event, path string, data interface{}) {
+	d.PostEvent(ctx, config.EventContext{Event: event, Path: path}, data)
+}
+
+// PostEvent is like Post for the event and path of ec, and evaluates the when
+// expressions of callbacks against ec.
+func (d *Dispatcher) PostEvent(ctx context.Context, ec config.EventContext, data interface{}) {
+	ctx = context.WithoutCancel(ctx)
+	for _, cb := range d.matcher.MatchContext("post", ec) {
+		for _, endpoint := range cb.Endpoints {
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				if err := d.deliver(ctx, cb, endpoint, ec.Event, ec.Path, data); err != nil && d.OnError != nil {
+					d.OnError(err)
+				}
+			}()
+		}
+	}
+}
+
+// Wait blocks until all post deliveries started so far have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// deliver posts the event payload of cb to one endpoint, retrying failed attempts
+// as configured by cb.
+func (d *Dispatcher) deliver(ctx context.Context, cb config.CallbackDefinition, endpoint, event, path string, data interface{}) error {
+	deliveryErr := &DeliveryError{Callback: cb.Name, Endpoint: endpoint, URL: d.endpoints[endpoint]}
+	if deliveryErr.URL == "" {
+		deliveryErr.Err = fmt.Errorf("unknown endpoint")
+		return deliveryErr
+	}
+	var secret []byte
+	if cb.Secret != "" {
+		s, ok := d.secrets[cb.Secret]
+		if !ok {
+			deliveryErr.Err = fmt.Errorf("unknown secret %q", cb.Secret)
+			return deliveryErr
+		}
+		secret = []byte(s)
+	}
+
+	payload, err := json.Marshal(Event{
+		Event:    event,
+		Timing:   cb.Timing,
+		Path:     path,
+		Callback: cb.Name,
+		Time:     time.Now().UTC(),
+		Data:     data,
+	})
+	if err != nil {
+		deliveryErr.Err = fmt.Errorf("encoding payload: %w", err)
+		return deliveryErr
+	}
+
+	for {
+		deliveryErr.Attempts++
+		deliveryErr.StatusCode, deliveryErr.Body, deliveryErr.Err = d.attempt(ctx, cb, deliveryErr.URL, payload, secret)
+		if deliveryErr.Err == nil && deliveryErr.StatusCode >= 200 && deliveryErr.StatusCode <= 299 {
+			return nil
+		}
+		if deliveryErr.Attempts > cb.Retries || !retryable(ctx, deliveryErr) {
+			return deliveryErr
+		}
+		timer := time.NewTimer(retryDelay(cb.Backoff, deliveryErr.Attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return deliveryErr
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt posts payload to url once, within the timeout of cb, or DefaultTimeout if
+// it has none. It returns the response status and the start of the response body.
+func (d *Dispatcher) attempt(ctx context.Context, cb config.CallbackDefinition, url string, payload, secret []byte) (int, string, error) {
+	timeout := cb.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != nil {
+		// Sign every attempt anew so that retries are not rejected as replays.
+		req.Header.Set(SignatureHeader, Sign(secret, time.Now(), payload))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, strings.TrimSpace(string(body)), nil
+	}
+	return resp.StatusCode, "", nil
+}
+
+// retryable reports whether the failed attempt described by err is worth retrying:
+// transport errors and timeouts, server errors and 429 Too Many Requests are, other
+// responses are deliberate and the cancellation of ctx ends the delivery.
+func retryable(ctx context.Context, err *DeliveryError) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return err.Err != nil || err.StatusCode >= 500 || err.StatusCode == http.StatusTooManyRequests
+}
+
+// retryDelay returns how long to wait after the given number of failed attempts:
+// backoff (DefaultBackoff if zero) doubled for each attempt after the first, capped
+// at config.MaxCallbackBackoff, with random jitter taking off up to half of it so
+// that retries of concurrent deliveries spread out.
+func retryDelay(backoff time.Duration, attempts int) time.Duration {
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	delay := backoff
+	for i := 1; i < attempts && delay < config.MaxCallbackBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, config.MaxCallbackBackoff)
+	return delay - rand.N(delay/2+1)
+}
@@ -0,0 +1,289 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dropsite-ai/config"
+)
+
+// recorder is a test server recording the events it receives.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+	status int
+	body   string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var ev Event
+	if req.Header.Get("Content-Type") != "application/json" || json.NewDecoder(req.Body).Decode(&ev) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	status, body := r.status, r.body
+	r.mu.Unlock()
+	if status != 0 {
+		http.Error(w, body, status)
+	}
+}
+
+func (r *recorder) received() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func TestDispatcher(t *testing.T) {
+	allow := &recorder{}
+	deny := &recorder{status: http.StatusForbidden, body: "read-only project"}
+	allowServer := httptest.NewServer(allow)
+	defer allowServer.Close()
+	denyServer := httptest.NewServer(deny)
+	defer denyServer.Close()
+
+	vars := &config.Variables{Endpoints: map[string]string{
+		"allow": allowServer.URL,
+		"deny":  denyServer.URL,
+		"down":  "http://127.0.0.1:1",
+	}}
+	callbacks := []config.CallbackDefinition{
+		{Name: "audit", Events: []string{"file.*"}, Timing: "pre", Target: config.CallbackTarget{Type: "directory", Path: "/projects"}, Endpoints: []string{"allow"}},
+		{Name: "guard", Events: []string{"file.delete"}, Timing: "pre", Target: config.CallbackTarget{Type: "directory", Path: "/projects/locked"}, Endpoints: []string{"deny"}},
+		{Name: "notify", Events: []string{"file.write"}, Timing: "post", Target: config.CallbackTarget{Type: "directory", Path: "/projects"}, Endpoints: []string{"allow", "down"}},
+	}
+	d := New(vars, callbacks)
+	var mu sync.Mutex
+	var postErrs []error
+	d.OnError = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		postErrs = append(postErrs, err)
+	}
+	ctx := context.Background()
+
+	t.Run("Pre callbacks allow the operation", func(t *testing.T) {
+		if err := d.Pre(ctx, "file.write", "/projects/a.md", map[string]int{"size": 3}); err != nil {
+			t.Fatalf("expected no veto, got %v", err)
+		}
+		events := allow.received()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %v", events)
+		}
+		ev := events[0]
+		if ev.Event != "file.write" || ev.Timing != "pre" || ev.Path != "/projects/a.md" || ev.Callback != "audit" || ev.Time.IsZero() {
+			t.Errorf("unexpected payload %+v", ev)
+		}
+		if data, ok := ev.Data.(map[string]interface{}); !ok || data["size"] != float64(3) {
+			t.Errorf("unexpected data %#v", ev.Data)
+		}
+	})
+
+	t.Run("Pre callbacks veto the operation", func(t *testing.T) {
+		err := d.Pre(ctx, "file.delete", "/projects/locked/a.md", nil)
+		var deliveryErr *DeliveryError
+		if !errors.As(err, &deliveryErr) {
+			t.Fatalf("expected a *DeliveryError, got %v", err)
+		}
+		if deliveryErr.Callback != "guard" || deliveryErr.StatusCode != http.StatusForbidden || deliveryErr.Body != "read-only project" {
+			t.Errorf("unexpected veto %+v", deliveryErr)
+		}
+		if want := `callback "guard" to endpoint "deny" returned 403: read-only project`; err.Error() != want {
+			t.Errorf("expected %q, got %q", want, err.Error())
+		}
+	})
+
+	t.Run("Post callbacks are delivered in the background", func(t *testing.T) {
+		before := len(allow.received())
+		d.Post(ctx, "file.write", "/projects/b.md", nil)
+		d.Wait()
+		events := allow.received()[before:]
+		if len(events) != 1 || events[0].Timing != "post" || events[0].Callback != "notify" {
+			t.Errorf("expected one post event, got %v", events)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var deliveryErr *DeliveryError
+		if len(postErrs) != 1 || !errors.As(postErrs[0], &deliveryErr) || deliveryErr.Endpoint != "down" || deliveryErr.Err == nil {
+			t.Errorf("expected the unreachable endpoint to be reported, got %v", postErrs)
+		}
+	})
+
+	t.Run("Unmatched events are not delivered", func(t *testing.T) {
+		before := len(allow.received())
+		if err := d.Pre(ctx, "file.write", "/other/a.md", nil); err != nil {
+			t.Fatalf("expected no veto, got %v", err)
+		}
+		d.Post(ctx, "file.read", "/projects/a.md", nil)
+		d.Wait()
+		if n := len(allow.received()) - before; n != 0 {
+			t.Errorf("expected no deliveries, got %d", n)
+		}
+	})
+}
+
+// flaky is a test server failing with status the first failures requests.
+type flaky struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	delay    time.Duration
+	requests int
+}
+
+func (f *flaky) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	fail := f.requests <= f.failures
+	f.mu.Unlock()
+	// Drain the body so that the server notices when the client gives up.
+	io.Copy(io.Discard, req.Body)
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-req.Context().Done():
+		}
+	}
+	if fail {
+		http.Error(w, "try again", f.status)
+	}
+}
+
+func (f *flaky) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   *flaky
+		cb       config.CallbackDefinition
+		wantErr  string
+		requests int
+	}{
+		{
+			name:     "Server errors are retried",
+			server:   &flaky{failures: 2, status: http.StatusServiceUnavailable},
+			cb:       config.CallbackDefinition{Retries: 2, Backoff: time.Millisecond},
+			requests: 3,
+		},
+		{
+			name:     "Retries run out",
+			server:   &flaky{failures: 5, status: http.StatusTooManyRequests},
+			cb:       config.CallbackDefinition{Retries: 1, Backoff: time.Millisecond},
+			wantErr:  `callback "cb" to endpoint "flaky" (2 attempts) returned 429: try again`,
+			requests: 2,
+		},
+		{
+			name:     "Rejections are not retried",
+			server:   &flaky{failures: 5, status: http.StatusForbidden},
+			cb:       config.CallbackDefinition{Retries: 3, Backoff: time.Millisecond},
+			wantErr:  `callback "cb" to endpoint "flaky" returned 403: try again`,
+			requests: 1,
+		},
+		{
+			name:     "Attempts time out",
+			server:   &flaky{delay: time.Second},
+			cb:       config.CallbackDefinition{Timeout: 10 * time.Millisecond, Retries: 1, Backoff: time.Millisecond},
+			wantErr:  "context deadline exceeded",
+			requests: 2,
+		},
+		{
+			name:     "Ignored failures do not veto",
+			server:   &flaky{failures: 5, status: http.StatusForbidden},
+			cb:       config.CallbackDefinition{OnFailure: config.OnFailureIgnore},
+			requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.server)
+			defer server.Close()
+			cb := tt.cb
+			cb.Name, cb.Events, cb.Timing = "cb", []string{"file.write"}, "pre"
+			cb.Target = config.CallbackTarget{Type: "directory", Path: "/"}
+			cb.Endpoints = []string{"flaky"}
+			d := New(&config.Variables{Endpoints: map[string]string{"flaky": server.URL}}, []config.CallbackDefinition{cb})
+			var ignored []error
+			d.OnError = func(err error) { ignored = append(ignored, err) }
+
+			err := d.Pre(context.Background(), "file.write", "/a.md", nil)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if n := tt.server.count(); n != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, n)
+			}
+			if cb.OnFailure == config.OnFailureIgnore && len(ignored) != 1 {
+				t.Errorf("expected the ignored failure to be reported, got %v", ignored)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 20: config.MaxCallbackBackoff} {
+		for range 10 {
+			if delay := retryDelay(100*time.Millisecond, attempts); delay < want/2 || delay > want {
+				t.Errorf("retry delay after %d attempts: expected between %v and %v, got %v", attempts, want/2, want, delay)
+			}
+		}
+	}
+	if delay := retryDelay(0, 1); delay < DefaultBackoff/2 || delay > DefaultBackoff {
+		t.Errorf("expected the default backoff, got %v", delay)
+	}
+}
+
+// slowTransport fakes a server taking delay to answer, failing requests whose
+// deadline comes first without waiting for it.
+type slowTransport struct {
+	delay time.Duration
+}
+
+func (s slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < s.delay {
+		return nil, context.DeadlineExceeded
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestTimeouts(t *testing.T) {
+	cb := config.CallbackDefinition{Name: "slow", Events: []string{"file.write"}, Timing: "pre",
+		Target: config.CallbackTarget{Type: "directory", Path: "/"}, Endpoints: []string{"slow"}}
+	vars := &config.Variables{Endpoints: map[string]string{"slow": "http://slow.invalid"}}
+
+	// A 15 second answer fits a 30 second callback timeout, longer than the default.
+	cb.Timeout = 30 * time.Second
+	d := New(vars, []config.CallbackDefinition{cb})
+	d.Client.Transport = slowTransport{delay: 15 * time.Second}
+	if err := d.Pre(context.Background(), "file.write", "/a", nil); err != nil {
+		t.Errorf("expected the callback timeout to allow a 15s answer, got %v", err)
+	}
+
+	// Without a timeout, attempts are bounded by DefaultTimeout.
+	cb.Timeout = 0
+	d = New(vars, []config.CallbackDefinition{cb})
+	d.Client.Transport = slowTransport{delay: DefaultTimeout + time.Second}
+	if err := d.Pre(context.Background(), "file.write", "/a", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the default timeout to be exceeded, got %v", err)
+	}
+	d.Client.Transport = slowTransport{delay: DefaultTimeout - time.Second}
+	if err := d.Pre(context.Background(), "file.write", "/a", nil); err != nil {
+		t.Errorf("expected the default timeout to allow a %v answer, got %v", DefaultTimeout-time.Second, err)
+	}
+}
@@ -0,0 +1,97 @@
+package dispatch
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the request header carrying the payload signature, in the form
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">".
+const SignatureHeader = "X-LLMFS-Signature"
+
+// DefaultReplayWindow is how far a signature timestamp may be from the receiver's
+// clock before VerifyRequest rejects it.
+const DefaultReplayWindow = 5 * time.Minute
+
+// Errors returned when verifying a signature.
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("signature timestamp outside the replay window")
+)
+
+// Sign returns the SignatureHeader value for body, signed with secret at time t.
+func Sign(secret []byte, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac(secret, timestamp, body))
+}
+
+// Verify checks a SignatureHeader value against body and secret. The signature must
+// have been made within window of now, in either direction, so a captured request
+// cannot be replayed later; receivers that must also reject replays within the window
+// should remember the signatures they have seen for its duration.
+func Verify(secret []byte, header string, body []byte, now time.Time, window time.Duration) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return fmt.Errorf("%w: malformed %s header", ErrInvalidSignature, SignatureHeader)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > window || age < -window {
+		return fmt.Errorf("%w: signed %s ago", ErrExpiredSignature, age.Round(time.Second))
+	}
+	expected := mac(secret, timestamp, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// VerifyRequest reads the body of a signed callback request and verifies its
+// signature with secret and DefaultReplayWindow. It returns the body, which can be
+// decoded as an Event, and replaces r.Body so it can be read again.
+func VerifyRequest(r *http.Request, secret []byte) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := Verify(secret, r.Header.Get(SignatureHeader), body, time.Now(), DefaultReplayWindow); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// mac returns the HMAC-SHA256 of "<timestamp>.<body>".
+func mac(secret []byte, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,96 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropsite-ai/config"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"event":"file.write"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign(secret, now, body)
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Fatalf("unexpected header %q", header)
+	}
+
+	cases := []struct {
+		name    string
+		secret  []byte
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{"Valid", secret, header, body, now.Add(time.Minute), nil},
+		{"Rotated secrets", secret, Sign([]byte("old"), now, body) + "," + strings.Split(header, ",")[1], body, now, nil},
+		{"Wrong secret", []byte("other"), header, body, now, ErrInvalidSignature},
+		{"Tampered body", secret, header, []byte(`{"event":"file.delete"}`), now, ErrInvalidSignature},
+		{"Too old", secret, header, body, now.Add(6 * time.Minute), ErrExpiredSignature},
+		{"From the future", secret, header, body, now.Add(-6 * time.Minute), ErrExpiredSignature},
+		{"Missing", secret, "", body, now, ErrMissingSignature},
+		{"Malformed", secret, "v1=abc", body, now, ErrInvalidSignature},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Verify(c.secret, c.header, c.body, c.now, DefaultReplayWindow)
+			if !errors.Is(err, c.wantErr) || (c.wantErr == nil && err != nil) {
+				t.Errorf("expected %v, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSignedDelivery(t *testing.T) {
+	var verified []Event
+	var verifyErrs []error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := VerifyRequest(r, []byte("hook-secret"))
+		if err != nil {
+			verifyErrs = append(verifyErrs, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var ev Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		verified = append(verified, ev)
+	}))
+	defer server.Close()
+
+	vars := &config.Variables{
+		Endpoints: map[string]string{"hook": server.URL},
+		Secrets:   map[string]string{"hook": "hook-secret", "other": "wrong"},
+	}
+	target := config.CallbackTarget{Type: "directory", Path: "/"}
+	d := New(vars, []config.CallbackDefinition{
+		{Name: "signed", Events: []string{"file.write"}, Timing: "pre", Target: target, Endpoints: []string{"hook"}, Secret: "hook"},
+		{Name: "wrong", Events: []string{"file.delete"}, Timing: "pre", Target: target, Endpoints: []string{"hook"}, Secret: "other"},
+		{Name: "unsigned", Events: []string{"file.read"}, Timing: "pre", Target: target, Endpoints: []string{"hook"}},
+	})
+
+	if err := d.Pre(context.Background(), "file.write", "/a", nil); err != nil {
+		t.Fatalf("expected the signed delivery to be accepted, got %v", err)
+	}
+	if len(verified) != 1 || verified[0].Callback != "signed" {
+		t.Errorf("expected one verified event, got %v", verified)
+	}
+	if err := d.Pre(context.Background(), "file.delete", "/a", nil); err == nil {
+		t.Error("expected a delivery signed with the wrong secret to be rejected")
+	}
+	if err := d.Pre(context.Background(), "file.read", "/a", nil); err == nil {
+		t.Error("expected an unsigned delivery to be rejected")
+	}
+	if len(verifyErrs) != 2 || !errors.Is(verifyErrs[0], ErrInvalidSignature) || !errors.Is(verifyErrs[1], ErrMissingSignature) {
+		t.Errorf("unexpected verification errors %v", verifyErrs)
+	}
+}
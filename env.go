@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is prepended to the names of environment variables that override
+// processed variables.
+const envPrefix = "CONFIG"
+
+// EnvVarName returns the name of the environment variable that overrides the
+// given key, e.g. EnvVarName("variables", "endpoints", "service1") returns
+// "CONFIG_VARIABLES_ENDPOINTS_SERVICE1". Letters are upper-cased and any other
+// character that is not a digit is replaced with an underscore.
+func EnvVarName(prefix, section, key string) string {
+	name := envPrefix + "_" + prefix + "_" + section + "_" + key
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// applyVariableOverrides applies environment overrides to each section of the
+// variables mapping at prefix in doc, skipping sections that are missing or malformed.
+func applyVariableOverrides(doc *yaml.Node, prefix string) {
+	root, err := lookupSection(doc, prefix, nil)
+	if err != nil || root == nil {
+		return
+	}
+	for _, section := range variableSections {
+		if node := resolveAlias(mappingValue(root, section)); node != nil {
+			applyEnvOverrides(node, prefix, section)
+		}
+	}
+}
+
+// applyEnvOverrides replaces the value of every scalar entry in the mapping node
+// with the matching environment variable, if one is set.
+func applyEnvOverrides(node *yaml.Node, prefix, section string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+		if valueNode.Kind != yaml.ScalarNode {
+			continue
+		}
+		if value, ok := os.LookupEnv(EnvVarName(prefix, section, keyNode.Value)); ok {
+			valueNode.Value = value
+			valueNode.Tag = "!!str"
+		}
+	}
+}
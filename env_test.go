@@ -0,0 +1,90 @@
+package config
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvVarName(t *testing.T) {
+	cases := []struct {
+		prefix, section, key string
+		want                 string
+	}{
+		{"variables", "endpoints", "service1", "CONFIG_VARIABLES_ENDPOINTS_SERVICE1"},
+		{"variables", "paths", "data-dir", "CONFIG_VARIABLES_PATHS_DATA_DIR"},
+		{"app.vars", "users", "Admin", "CONFIG_APP_VARS_USERS_ADMIN"},
+	}
+	for _, c := range cases {
+		if got := EnvVarName(c.prefix, c.section, c.key); got != c.want {
+			t.Errorf("EnvVarName(%q, %q, %q) = %q, want %q", c.prefix, c.section, c.key, got, c.want)
+		}
+	}
+}
+
+func TestProcessVariablesEnvOverrides(t *testing.T) {
+	yamlStr := `
+variables:
+  endpoints:
+    service1: "http://example.com"
+  secrets:
+    secret1: ""
+  users:
+    user1: "root"
+  paths:
+    path1: "/tmp"
+`
+	t.Run("Overrides are applied to node and maps", func(t *testing.T) {
+		t.Setenv("CONFIG_VARIABLES_ENDPOINTS_SERVICE1", "https://override.example.com")
+		t.Setenv("CONFIG_VARIABLES_SECRETS_SECRET1", "fromenv")
+		t.Setenv("CONFIG_VARIABLES_USERS_USER1", "admin")
+		t.Setenv("CONFIG_VARIABLES_PATHS_PATH1", "/srv/data")
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+			t.Fatalf("failed to unmarshal YAML: %v", err)
+		}
+		vars, err := ProcessVariables(&doc, "variables")
+		if err != nil {
+			t.Fatalf("ProcessVariables returned error: %v", err)
+		}
+		if vars.Endpoints["service1"] != "https://override.example.com" {
+			t.Errorf("expected overridden endpoint, got %q", vars.Endpoints["service1"])
+		}
+		if vars.Secrets["secret1"] != "fromenv" {
+			t.Errorf("expected overridden secret, got %q", vars.Secrets["secret1"])
+		}
+		if vars.Users["user1"] != "admin" {
+			t.Errorf("expected overridden user, got %q", vars.Users["user1"])
+		}
+		if vars.Paths["path1"] != "/srv/data" {
+			t.Errorf("expected overridden path, got %q", vars.Paths["path1"])
+		}
+
+		var endpoint string
+		if err := yamledit.ReadNode(&doc, "variables.endpoints.service1", &endpoint); err != nil {
+			t.Fatalf("failed to re-read endpoint: %v", err)
+		}
+		if endpoint != "https://override.example.com" {
+			t.Errorf("YAML node not updated: got %q", endpoint)
+		}
+	})
+
+	t.Run("Overridden values are validated", func(t *testing.T) {
+		t.Setenv("CONFIG_VARIABLES_USERS_USER1", "Not Valid")
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+			t.Fatalf("failed to unmarshal YAML: %v", err)
+		}
+		_, err := ProcessVariables(&doc, "variables")
+		if err == nil {
+			t.Fatal("expected error due to invalid overridden username, got nil")
+		}
+		if !regexp.MustCompile(`invalid username`).MatchString(err.Error()) {
+			t.Errorf("expected error message to mention 'invalid username', got %v", err)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sentinel errors identifying the kind of a configuration problem, for use with errors.Is.
+var (
+	ErrInvalidURL        = errors.New("invalid URL")
+	ErrInvalidUsername   = errors.New("invalid username")
+	ErrInvalidPath       = errors.New("invalid path")
+	ErrInvalidTiming     = errors.New("invalid timing")
+	ErrInvalidTargetType = errors.New("invalid target type")
+	ErrInvalidPattern    = errors.New("invalid target pattern")
+	ErrUnknownEndpoint   = errors.New("unknown endpoint")
+	ErrUnknownSecret     = errors.New("unknown secret")
+	ErrInvalidDelivery   = errors.New("invalid delivery setting")
+	ErrInvalidFilter     = errors.New("invalid filter")
+	ErrDuplicateCallback = errors.New("duplicate callback name")
+	ErrUnknownEvent      = errors.New("unknown event")
+	ErrUnknownCallback   = errors.New("unknown callback")
+	ErrUnknownReference  = errors.New("unknown reference")
+	ErrReferenceCycle    = errors.New("reference cycle")
+	ErrIncludeCycle      = errors.New("include cycle")
+	ErrDuplicateKey      = errors.New("duplicate key")
+	ErrMalformedSection  = errors.New("malformed section")
+	ErrUnknownField      = errors.New("unknown field")
+	ErrMissingField      = errors.New("missing required field")
+)
+
+// FieldError describes a problem with one value of a configuration, such as an
+// invalid endpoint or a callback with an unknown timing.
+type FieldError struct {
+	Section string // dot-notation path of the section, such as "variables.endpoints" or "callbacks"
+	Key     string // key within the section, or the callback name
+	Value   string // offending value
+	Reason  string // what is wrong, such as "invalid endpoint"
+	File    string // source file, if known
+	Line    int    // position of the value, if known
+	Column  int
+	Kind    error // sentinel such as ErrInvalidURL matched by errors.Is, or nil
+	Err     error // underlying cause, or nil
+
+	// Suggestion is the nearest valid value for a likely typo, such as "timing"
+	// for an unknown field "timming", or "".
+	Suggestion string
+
+	callback bool // Key names a callback
+}
+
+// Error formats the problem as "<reason> for <key> in <file>:<line>:<column>: <cause>",
+// using the offending value when there is no underlying cause, followed by the
+// suggestion if there is one.
+func (e *FieldError) Error() string {
+	subject := fmt.Sprintf("%q", e.Key)
+	if e.callback {
+		subject = "callback " + subject
+	}
+	detail := fmt.Sprintf("%q", e.Value)
+	if e.Err != nil {
+		detail = e.Err.Error()
+	}
+	if e.Suggestion != "" {
+		detail += fmt.Sprintf(" (did you mean %q?)", e.Suggestion)
+	}
+	return fmt.Sprintf("%s for %s%s: %s", e.Reason, subject, location(e.File, e.Line, e.Column), detail)
+}
+
+// Is reports whether target is the sentinel identifying the kind of problem.
+func (e *FieldError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// Unwrap returns the underlying cause.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// at sets the position of e to that of node and returns e.
+func (e *FieldError) at(node *yaml.Node, src sources) *FieldError {
+	if node != nil {
+		e.File, e.Line, e.Column = src[node], node.Line, node.Column
+	}
+	return e
+}
+
+// ValidationErrors collects every problem found while processing a configuration,
+// so they can all be fixed at once. It unwraps to the individual errors, which
+// makes errors.Is and errors.As see each of them, as with errors.Join.
+type ValidationErrors []error
+
+// Error lists the errors, one per line.
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the collected errors.
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
+
+// add appends err, flattening nested ValidationErrors. Nil errors are ignored.
+func (e *ValidationErrors) add(err error) {
+	switch err := err.(type) {
+	case nil:
+	case ValidationErrors:
+		*e = append(*e, err...)
+	default:
+		*e = append(*e, err)
+	}
+}
+
+// err returns the collected errors, or nil if there are none.
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestValidationErrors(t *testing.T) {
+	t.Run("Variables report every invalid value", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte(`
+variables:
+  endpoints:
+    api: "not a url"
+    broken: "${endpoints.missing}"
+    ok: "http://example.com"
+  users:
+    admin: "BAD"
+    guest: "Bad Too"
+`))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		_, err = ProcessVariables(doc, "variables")
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("expected ValidationErrors, got %v", err)
+		}
+		want := []string{
+			`unresolved reference for "broken" at line 5, column 13: unknown reference "endpoints.missing"`,
+			`invalid endpoint for "api"`,
+			`invalid username for "admin"`,
+			`invalid username for "guest"`,
+		}
+		if len(errs) != len(want) {
+			t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), err)
+		}
+		for i, w := range want {
+			if !regexp.MustCompile(w).MatchString(errs[i].Error()) {
+				t.Errorf("error %d: expected %q, got %v", i, w, errs[i])
+			}
+		}
+	})
+
+	t.Run("Callbacks report every problem", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte(`
+callbacks:
+  - name: "first"
+    events: ["write"]
+    timing: "later"
+    target:
+      type: "disk"
+      path: "/x"
+    endpoints: ["missing"]
+  - name: "second"
+    events: ["read"]
+    timing: "pre"
+    target:
+      type: "file"
+      path: "/y"
+    endpoints: ["other"]
+`))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		_, err = ProcessCallbacks(doc, "callbacks", &Variables{})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 4 {
+			t.Fatalf("expected 4 errors, got %v", err)
+		}
+		for _, w := range []string{`invalid timing`, `invalid target type`, `unknown endpoint key for callback "first" .*: "missing"`, `unknown endpoint key for callback "second" .*: "other"`} {
+			if !regexp.MustCompile(w).MatchString(err.Error()) {
+				t.Errorf("expected %q in %v", w, err)
+			}
+		}
+	})
+
+	t.Run("Load combines variables and callbacks", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"config.yaml": `
+variables:
+  users:
+    admin: "BAD"
+callbacks:
+  - name: "cb"
+    events: ["write"]
+    timing: "later"
+    target:
+      type: "file"
+      path: "/x"
+    endpoints: []
+`})
+		_, _, _, err := Load(filepath.Join(dir, "config.yaml"), nil)
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 2 {
+			t.Fatalf("expected 2 errors, got %v", err)
+		}
+	})
+
+	t.Run("Compatible with errors.Is and errors.Join", func(t *testing.T) {
+		sentinel := errors.New("sentinel")
+		var errs ValidationErrors
+		errs.add(nil)
+		if errs.err() != nil {
+			t.Fatalf("expected no error for an empty collection")
+		}
+		errs.add(errors.New("first"))
+		errs.add(ValidationErrors{sentinel})
+		if len(errs) != 2 {
+			t.Fatalf("expected nested errors to be flattened, got %d", len(errs))
+		}
+		if !errors.Is(errs.err(), sentinel) {
+			t.Error("expected errors.Is to find the sentinel")
+		}
+		var found ValidationErrors
+		if !errors.As(errors.Join(errors.New("other"), errs), &found) || len(found) != 2 {
+			t.Error("expected errors.As to find ValidationErrors inside errors.Join")
+		}
+		if errs.Error() != "first\nsentinel" {
+			t.Errorf("unexpected message %q", errs.Error())
+		}
+	})
+}
+
+func TestFieldError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config.yaml": `variables:
+  endpoints:
+    api: "not a url"
+  users:
+    admin: "BAD"
+callbacks:
+  - name: "cb"
+    events: ["write"]
+    timing: "later"
+    target:
+      type: "disk"
+      path: "/x"
+    endpoints: ["missing"]
+`})
+	path := filepath.Join(dir, "config.yaml")
+	_, _, _, err := Load(path, nil)
+	if err == nil {
+		t.Fatal("expected errors, got nil")
+	}
+	for _, sentinel := range []error{ErrInvalidURL, ErrInvalidUsername, ErrInvalidTiming, ErrInvalidTargetType, ErrUnknownEndpoint} {
+		if !errors.Is(err, sentinel) {
+			t.Errorf("expected errors.Is to match %v", sentinel)
+		}
+	}
+	if errors.Is(err, ErrInvalidPath) {
+		t.Error("expected ErrInvalidPath not to match")
+	}
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	var fieldErr *FieldError
+	for _, e := range errs {
+		if errors.Is(e, ErrInvalidUsername) && errors.As(e, &fieldErr) {
+			break
+		}
+	}
+	if fieldErr == nil {
+		t.Fatalf("expected a *FieldError for the invalid username, got %v", err)
+	}
+	want := FieldError{Section: "variables.users", Key: "admin", Value: "BAD", Reason: "invalid username", File: path, Line: 5, Column: 12}
+	if fieldErr.Section != want.Section || fieldErr.Key != want.Key || fieldErr.Value != want.Value ||
+		fieldErr.Reason != want.Reason || fieldErr.File != want.File || fieldErr.Line != want.Line || fieldErr.Column != want.Column {
+		t.Errorf("expected %+v, got %+v", want, *fieldErr)
+	}
+
+	t.Run("Without a cause the value is shown", func(t *testing.T) {
+		e := &FieldError{Key: "cb", Value: "later", Reason: "invalid timing", Line: 3, Column: 7, Kind: ErrInvalidTiming, callback: true}
+		if got := e.Error(); got != `invalid timing for callback "cb" at line 3, column 7: "later"` {
+			t.Errorf("unexpected message %q", got)
+		}
+	})
+
+	t.Run("Reference cycles", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte("variables:\n  paths:\n    a: \"${paths.b}\"\n    b: \"${paths.a}\"\n"))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		_, err = ProcessVariables(doc, "variables")
+		if !errors.Is(err, ErrReferenceCycle) {
+			t.Errorf("expected ErrReferenceCycle, got %v", err)
+		}
+	})
+
+	t.Run("Include cycles and duplicate keys", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"a.yaml":        "include: b.yaml\n",
+			"b.yaml":        "include: a.yaml\n",
+			"conf.d/1.yaml": "name: one\n",
+			"conf.d/2.yaml": "name: two\n",
+		})
+		if _, _, _, err := Load(filepath.Join(dir, "a.yaml"), nil); !errors.Is(err, ErrIncludeCycle) {
+			t.Errorf("expected ErrIncludeCycle, got %v", err)
+		}
+		if _, _, _, err := LoadDir(filepath.Join(dir, "conf.d")); !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected ErrDuplicateKey, got %v", err)
+		}
+	})
+}
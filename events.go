@@ -0,0 +1,78 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// EventCatalog is the set of events callbacks may subscribe to. Callbacks may also
+// use wildcards: "*" matches every event and "file.*" every event starting with "file.".
+// A catalog is not safe for concurrent use while events are being registered.
+type EventCatalog struct {
+	events []string // sorted and unique
+}
+
+// NewEventCatalog returns a catalog holding the given events.
+func NewEventCatalog(events ...string) *EventCatalog {
+	c := &EventCatalog{}
+	c.Register(events...)
+	return c
+}
+
+// FileEvents returns a new catalog of the file operations LLMFS reports, to which
+// an application may register its own events.
+func FileEvents() *EventCatalog {
+	return NewEventCatalog(
+		"file.create",
+		"file.read",
+		"file.write",
+		"file.delete",
+		"file.rename",
+		"file.list",
+	)
+}
+
+// Register adds events to the catalog.
+func (c *EventCatalog) Register(events ...string) {
+	c.events = append(c.events, events...)
+	slices.Sort(c.events)
+	c.events = slices.Compact(c.events)
+}
+
+// Events returns the registered events in sorted order.
+func (c *EventCatalog) Events() []string {
+	return slices.Clone(c.events)
+}
+
+// Valid reports whether event is registered or is a wildcard matching at least one
+// registered event.
+func (c *EventCatalog) Valid(event string) bool {
+	for _, e := range c.events {
+		if MatchEvent(event, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchEvent reports whether the event pattern of a callback matches event. A pattern
+// is an event name, "*" for every event, or a prefix followed by ".*", such as
+// "file.*", for every event under that prefix.
+func MatchEvent(pattern, event string) bool {
+	if pattern == "*" || pattern == event {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, ".") {
+		return strings.HasPrefix(event, prefix)
+	}
+	return false
+}
+
+// WithEvents makes callback events that are not in catalog, or wildcards that match
+// none of its events, invalid. They are reported with the nearest registered event
+// as a suggestion. Without it, events are not checked.
+func WithEvents(catalog *EventCatalog) Option {
+	return func(o *options) {
+		o.events = catalog
+	}
+}
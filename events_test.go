@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestMatchEvent(t *testing.T) {
+	cases := []struct {
+		pattern, event string
+		want           bool
+	}{
+		{"file.write", "file.write", true},
+		{"file.write", "file.read", false},
+		{"*", "app.deploy", true},
+		{"file.*", "file.write", true},
+		{"file.*", "filesystem.write", false},
+		{"file.*", "app.file.write", false},
+		{"file*", "file.write", false},
+	}
+	for _, c := range cases {
+		if got := MatchEvent(c.pattern, c.event); got != c.want {
+			t.Errorf("MatchEvent(%q, %q) = %v, want %v", c.pattern, c.event, got, c.want)
+		}
+	}
+}
+
+func TestEventCatalog(t *testing.T) {
+	catalog := FileEvents()
+	catalog.Register("app.deploy", "file.write")
+	for _, event := range []string{"file.write", "file.*", "app.deploy", "app.*", "*"} {
+		if !catalog.Valid(event) {
+			t.Errorf("expected %q to be valid", event)
+		}
+	}
+	for _, event := range []string{"file.wrte", "db.*", "deploy"} {
+		if catalog.Valid(event) {
+			t.Errorf("expected %q to be invalid", event)
+		}
+	}
+	if n := len(catalog.Events()); n != len(FileEvents().Events())+1 {
+		t.Errorf("expected registered events to be deduplicated, got %v", catalog.Events())
+	}
+
+	doc, err := yamledit.Parse([]byte(`callbacks:
+  - name: "cb"
+    events: ["file.write", "file.wrte", "file.*", "db.*"]
+    timing: "pre"
+    target: {type: "file", path: "/a"}
+    endpoints: []
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	if _, err := ProcessCallbacks(doc, "callbacks", &Variables{}); err != nil {
+		t.Fatalf("expected events not to be checked without WithEvents, got %v", err)
+	}
+	_, err = ProcessCallbacks(doc, "callbacks", &Variables{}, WithEvents(catalog))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || !errors.Is(err, ErrUnknownEvent) {
+		t.Fatalf("expected 2 unknown events, got %v", err)
+	}
+	for _, want := range []string{
+		`unknown event for callback "cb" at line 3, column 28: "file.wrte" \(did you mean "file.write"\?\)`,
+		`unknown event for callback "cb" at line 3, column 51: "db.\*"$`,
+	} {
+		if !regexp.MustCompile(`(?m)` + want).MatchString(err.Error()) {
+			t.Errorf("expected error matching %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EventContext describes the operation an event is about. Filters in the when field
+// of callbacks are evaluated against it; facts that are not known are left empty.
+type EventContext struct {
+	Event string // event name, e.g. "file.write"
+	Path  string // path of the file or directory
+	User  string // user performing the operation
+	Size  int64  // size of the file in bytes
+	MIME  string // MIME type of the file, e.g. "image/png"
+}
+
+// Filter is a compiled when expression. Expressions combine comparisons of the
+// variables below with &&, || and !, and parentheses:
+//
+//	event, user, mime   the EventContext fields
+//	path                the path, cleaned
+//	name, dir           the last element of the path and the directory holding it
+//	ext                 the extension of the name, lower-cased and with its dot
+//	size                the size in bytes
+//
+// Strings are quoted with " or ' and compared with == and !=, numbers are compared
+// with ==, !=, <, <=, > and >= and may have a KB, MB, GB or TB suffix (powers of
+// 1024). x in [a, b] tests whether x equals one of a list of literals, x matches
+// "pattern" matches a string against a glob pattern like glob targets, and
+// x =~ "expr" against a regular expression, which must match the whole string.
+// For example:
+//
+//	ext in [".jpg", ".png"] && size > 10MB && user != "backup"
+//	mime matches "image/*" || !(dir matches "/tmp/**")
+type Filter struct {
+	expr string
+	eval func(*EventContext) bool
+}
+
+// CompileFilter parses and type-checks a when expression.
+func CompileFilter(expr string) (*Filter, error) {
+	p := &filterParser{expr: expr}
+	p.next()
+	e, err := p.parseOr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf(p.tok, "unexpected %s", p.tok)
+	}
+	if err == nil && e.typ != typeBool {
+		err = fmt.Errorf("column %d: expression is a %s, not a condition", e.col, e.typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{expr: expr, eval: e.b}, nil
+}
+
+// Match reports whether the filter holds for ctx.
+func (f *Filter) Match(ctx EventContext) bool {
+	return f.eval(&ctx)
+}
+
+// String returns the source expression.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// exprType is the type of a filter expression.
+type exprType int
+
+const (
+	typeBool exprType = iota
+	typeNumber
+	typeString
+)
+
+func (t exprType) String() string {
+	return [...]string{"condition", "number", "string"}[t]
+}
+
+// expr is a type-checked filter expression, compiled to the evaluation function of
+// its type.
+type expr struct {
+	typ exprType
+	col int // 1-based column of the expression in the source
+	b   func(*EventContext) bool
+	n   func(*EventContext) int64
+	s   func(*EventContext) string
+	// lit is the value of a literal, as lists must be known when the filter is
+	// compiled.
+	lit interface{}
+}
+
+// filterVariables are the variables filters can use.
+var filterVariables = map[string]expr{
+	"event": {typ: typeString, s: func(c *EventContext) string { return c.Event }},
+	"path":  {typ: typeString, s: func(c *EventContext) string { return cleanPath(c.Path) }},
+	"name":  {typ: typeString, s: func(c *EventContext) string { return path.Base(cleanPath(c.Path)) }},
+	"dir":   {typ: typeString, s: func(c *EventContext) string { return path.Dir(cleanPath(c.Path)) }},
+	"ext":   {typ: typeString, s: func(c *EventContext) string { return strings.ToLower(path.Ext(cleanPath(c.Path))) }},
+	"user":  {typ: typeString, s: func(c *EventContext) string { return c.User }},
+	"mime":  {typ: typeString, s: func(c *EventContext) string { return c.MIME }},
+	"size":  {typ: typeNumber, n: func(c *EventContext) int64 { return c.Size }},
+}
+
+// cleanPath cleans p, keeping an empty path empty.
+func cleanPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Clean(p)
+}
+
+// sizeUnits are the suffixes allowed on number literals.
+var sizeUnits = map[string]int64{"B": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30, "TB": 1 << 40}
+
+// tokenKind is the kind of a filter token.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp // operators and punctuation
+	tokInvalid
+)
+
+// token is a lexical token of a filter expression.
+type token struct {
+	kind tokenKind
+	text string // source text, or the value of a string
+	num  int64
+	col  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// filterOps are the operators and punctuation of filters, longest first.
+var filterOps = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")", "[", "]", ","}
+
+// filterParser is a recursive descent parser for filter expressions. It reads one
+// token ahead.
+type filterParser struct {
+	expr string
+	pos  int // byte offset of the next token
+	tok  token
+}
+
+// next reads the next token into p.tok.
+func (p *filterParser) next() {
+	for p.pos < len(p.expr) && unicode.IsSpace(rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	col := len([]rune(p.expr[:start])) + 1
+	if start == len(p.expr) {
+		p.tok = token{kind: tokEOF, col: col}
+		return
+	}
+	rest := p.expr[start:]
+	c := rest[0]
+	r, size := utf8.DecodeRuneInString(rest)
+	switch {
+	case c == '"' || c == '\'':
+		end := 1
+		for end < len(rest) && rest[end] != c {
+			if rest[end] == '\\' && c == '"' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			p.tok = token{kind: tokInvalid, text: "unterminated string", col: col}
+			p.pos = len(p.expr)
+			return
+		}
+		text := rest[1:end]
+		if c == '"' {
+			var err error
+			if text, err = strconv.Unquote(rest[:end+1]); err != nil {
+				p.tok = token{kind: tokInvalid, text: "invalid string " + rest[:end+1], col: col}
+				p.pos += end + 1
+				return
+			}
+		}
+		p.tok = token{kind: tokString, text: text, col: col}
+		p.pos += end + 1
+	case c >= '0' && c <= '9':
+		end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) && !unicode.IsLetter(r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		text := rest[:end]
+		digits := strings.TrimRightFunc(text, unicode.IsLetter)
+		n, err := strconv.ParseInt(digits, 10, 64)
+		unit, ok := sizeUnits[strings.ToUpper(text[len(digits):])]
+		if text[len(digits):] == "" {
+			unit, ok = 1, true
+		}
+		if err != nil || !ok || n > (1<<63-1)/unit {
+			p.tok = token{kind: tokInvalid, text: fmt.Sprintf("invalid number %q", text), col: col}
+		} else {
+			p.tok = token{kind: tokNumber, text: text, num: n * unit, col: col}
+		}
+		p.pos += end
+	case c == '_' || unicode.IsLetter(r):
+		end := strings.IndexFunc(rest, func(r rune) bool { return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		p.tok = token{kind: tokIdent, text: rest[:end], col: col}
+		p.pos += end
+	default:
+		for _, op := range filterOps {
+			if strings.HasPrefix(rest, op) {
+				p.tok = token{kind: tokOp, text: op, col: col}
+				p.pos += len(op)
+				return
+			}
+		}
+		p.tok = token{kind: tokInvalid, text: fmt.Sprintf("unexpected character %q", r), col: col}
+		p.pos += size
+	}
+}
+
+// errorf returns an error at the column of tok. Invalid tokens report their own
+// problem instead.
+func (p *filterParser) errorf(tok token, format string, args ...interface{}) error {
+	if tok.kind == tokInvalid {
+		return fmt.Errorf("column %d: %s", tok.col, tok.text)
+	}
+	return fmt.Errorf("column %d: %s", tok.col, fmt.Sprintf(format, args...))
+}
+
+// is reports whether the current token is the operator or keyword s.
+func (p *filterParser) is(s string) bool {
+	return (p.tok.kind == tokOp || p.tok.kind == tokIdent) && p.tok.text == s
+}
+
+// parseOr parses a || b || ...
+func (p *filterParser) parseOr() (expr, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+// parseAnd parses a && b && ...
+func (p *filterParser) parseAnd() (expr, error) {
+	return p.parseLogical("&&", p.parseNot)
+}
+
+// parseLogical parses operands joined by the logical operator op.
+func (p *filterParser) parseLogical(op string, operand func() (expr, error)) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return expr{}, err
+	}
+	for p.is(op) {
+		opTok := p.tok
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return expr{}, err
+		}
+		if left.typ != typeBool || right.typ != typeBool {
+			return expr{}, p.errorf(opTok, "%s needs conditions on both sides, not a %s and a %s", op, left.typ, right.typ)
+		}
+		l, r := left.b, right.b
+		if op == "&&" {
+			left = expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return l(c) && r(c) }}
+		} else {
+			left = expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return l(c) || r(c) }}
+		}
+	}
+	return left, nil
+}
+
+// parseNot parses !a or a comparison.
+func (p *filterParser) parseNot() (expr, error) {
+	if !p.is("!") {
+		return p.parseComparison()
+	}
+	opTok := p.tok
+	p.next()
+	operand, err := p.parseNot()
+	if err != nil {
+		return expr{}, err
+	}
+	if operand.typ != typeBool {
+		return expr{}, p.errorf(opTok, "! needs a condition, not a %s", operand.typ)
+	}
+	b := operand.b
+	return expr{typ: typeBool, col: opTok.col, b: func(c *EventContext) bool { return !b(c) }}, nil
+}
+
+// parseComparison parses an operand, optionally compared with another one, a list
+// or a pattern.
+func (p *filterParser) parseComparison() (expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return expr{}, err
+	}
+	opTok := p.tok
+	switch {
+	case p.is("=="), p.is("!="), p.is("<"), p.is("<="), p.is(">"), p.is(">="):
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return expr{}, err
+		}
+		return compare(p, opTok, left, right)
+	case p.is("in"):
+		p.next()
+		return p.parseIn(opTok, left)
+	case p.is("matches"), p.is("=~"):
+		p.next()
+		patTok := p.tok
+		if patTok.kind != tokString {
+			return expr{}, p.errorf(patTok, "%s needs a quoted pattern, found %s", opTok.text, patTok)
+		}
+		p.next()
+		if left.typ != typeString {
+			return expr{}, p.errorf(opTok, "%s needs a string on the left, not a %s", opTok.text, left.typ)
+		}
+		var match func(string) bool
+		if opTok.text == "matches" {
+			match, err = compileTarget(CallbackTarget{Type: "glob", Path: patTok.text})
+		} else if _, err = regexp.Compile(patTok.text); err == nil {
+			match = regexp.MustCompile(`^(?:` + patTok.text + `)$`).MatchString
+		}
+		if err != nil {
+			return expr{}, p.errorf(patTok, "invalid pattern %q: %v", patTok.text, err)
+		}
+		s := left.s
+		return expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return match(s(c)) }}, nil
+	}
+	return left, nil
+}
+
+// parseIn parses the list of x in [a, b, ...].
+func (p *filterParser) parseIn(opTok token, left expr) (expr, error) {
+	if !p.is("[") {
+		return expr{}, p.errorf(p.tok, "in needs a list such as [\"a\", \"b\"], found %s", p.tok)
+	}
+	p.next()
+	var strs []string
+	var nums []int64
+	for !p.is("]") {
+		item, err := p.parseOperand()
+		if err != nil {
+			return expr{}, err
+		}
+		if item.lit == nil {
+			return expr{}, fmt.Errorf("column %d: lists can only hold literals", item.col)
+		}
+		if item.typ != left.typ {
+			return expr{}, fmt.Errorf("column %d: cannot look for a %s in a list holding a %s", item.col, left.typ, item.typ)
+		}
+		switch v := item.lit.(type) {
+		case string:
+			strs = append(strs, v)
+		case int64:
+			nums = append(nums, v)
+		}
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if !p.is("]") {
+		return expr{}, p.errorf(p.tok, "expected , or ] in list, found %s", p.tok)
+	}
+	p.next()
+	switch left.typ {
+	case typeString:
+		s := left.s
+		return expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return slices.Contains(strs, s(c)) }}, nil
+	case typeNumber:
+		n := left.n
+		return expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return slices.Contains(nums, n(c)) }}, nil
+	}
+	return expr{}, p.errorf(opTok, "in needs a string or number on the left, not a %s", left.typ)
+}
+
+// compare returns the comparison of left and right with the operator of opTok.
+func compare(p *filterParser, opTok token, left, right expr) (expr, error) {
+	op := opTok.text
+	if left.typ != right.typ {
+		return expr{}, p.errorf(opTok, "cannot compare a %s with a %s", left.typ, right.typ)
+	}
+	switch left.typ {
+	case typeNumber:
+		l, r := left.n, right.n
+		cmp := map[string]func(a, b int64) bool{
+			"==": func(a, b int64) bool { return a == b },
+			"!=": func(a, b int64) bool { return a != b },
+			"<":  func(a, b int64) bool { return a < b },
+			"<=": func(a, b int64) bool { return a <= b },
+			">":  func(a, b int64) bool { return a > b },
+			">=": func(a, b int64) bool { return a >= b },
+		}[op]
+		return expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return cmp(l(c), r(c)) }}, nil
+	case typeString:
+		l, r := left.s, right.s
+		switch op {
+		case "==":
+			return expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return l(c) == r(c) }}, nil
+		case "!=":
+			return expr{typ: typeBool, col: left.col, b: func(c *EventContext) bool { return l(c) != r(c) }}, nil
+		}
+	}
+	return expr{}, p.errorf(opTok, "cannot compare %ss with %s", left.typ, op)
+}
+
+// parseOperand parses a parenthesized expression, a literal or a variable.
+func (p *filterParser) parseOperand() (expr, error) {
+	tok := p.tok
+	switch {
+	case p.is("("):
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return expr{}, err
+		}
+		if !p.is(")") {
+			return expr{}, p.errorf(p.tok, "expected ), found %s", p.tok)
+		}
+		p.next()
+		e.col = tok.col
+		return e, nil
+	case tok.kind == tokString:
+		p.next()
+		return expr{typ: typeString, col: tok.col, lit: tok.text, s: func(*EventContext) string { return tok.text }}, nil
+	case tok.kind == tokNumber:
+		p.next()
+		return expr{typ: typeNumber, col: tok.col, lit: tok.num, n: func(*EventContext) int64 { return tok.num }}, nil
+	case tok.kind == tokIdent && (tok.text == "true" || tok.text == "false"):
+		p.next()
+		v := tok.text == "true"
+		return expr{typ: typeBool, col: tok.col, b: func(*EventContext) bool { return v }}, nil
+	case tok.kind == tokIdent:
+		v, ok := filterVariables[tok.text]
+		if !ok {
+			names := make([]string, 0, len(filterVariables))
+			for name := range filterVariables {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			msg := fmt.Sprintf("unknown variable %q", tok.text)
+			if s := suggest(tok.text, names); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			return expr{}, p.errorf(tok, "%s", msg)
+		}
+		p.next()
+		v.col = tok.col
+		return v, nil
+	}
+	return expr{}, p.errorf(tok, "expected a value, found %s", tok)
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestFilter(t *testing.T) {
+	photo := EventContext{Event: "file.write", Path: "/photos/2024/IMG_1.JPG", User: "alice", Size: 12 << 20, MIME: "image/jpeg"}
+	note := EventContext{Event: "file.write", Path: "/notes/todo.md", User: "backup", Size: 512, MIME: "text/markdown"}
+	cases := []struct {
+		expr        string
+		photo, note bool
+	}{
+		{`size > 10MB`, true, false},
+		{`size <= 512`, false, true},
+		{`size == 512B`, false, true},
+		{`ext == ".jpg"`, true, false},
+		{`ext in [".jpg", ".png"] && size > 10MB && user != "backup"`, true, false},
+		{`size in [512, 1KB]`, false, true},
+		{`mime matches "image/*"`, true, false},
+		{`path matches "/photos/**"`, true, false},
+		{`name =~ 'IMG_\d+\..*'`, true, false},
+		{`!(dir matches "/photos/**") || user == "alice"`, true, true},
+		{`user == 'backup' || event != "file.write"`, false, true},
+		{`!true || false`, false, false},
+		{`(size > 1KB)`, true, false},
+	}
+	for _, c := range cases {
+		f, err := CompileFilter(c.expr)
+		if err != nil {
+			t.Errorf("CompileFilter(%q) returned error: %v", c.expr, err)
+			continue
+		}
+		if got := f.Match(photo); got != c.photo {
+			t.Errorf("%q on the photo = %v, want %v", c.expr, got, c.photo)
+		}
+		if got := f.Match(note); got != c.note {
+			t.Errorf("%q on the note = %v, want %v", c.expr, got, c.note)
+		}
+		if f.String() != c.expr {
+			t.Errorf("expected String() %q, got %q", c.expr, f.String())
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{``, `column 1: expected a value, found end of expression`},
+		{`size >`, `column 7: expected a value, found end of expression`},
+		{`sise > 1`, `column 1: unknown variable "sise" (did you mean "size"?)`},
+		{`size > "1"`, `column 6: cannot compare a number with a string`},
+		{`ext < ".md"`, `column 5: cannot compare strings with <`},
+		{`size`, `column 1: expression is a number, not a condition`},
+		{`size > 1 && ext`, `column 10: && needs conditions on both sides, not a condition and a string`},
+		{`!user`, `column 1: ! needs a condition, not a string`},
+		{`size > 10XB`, `column 8: invalid number "10XB"`},
+		{`ext == ".md`, `column 8: unterminated string`},
+		{`ext == ".md" user`, `column 14: unexpected "user"`},
+		{`size > 1 ) `, `column 10: unexpected ")"`},
+		{`(size > 1`, `column 10: expected ), found end of expression`},
+		{`ext in ".md"`, `column 8: in needs a list such as ["a", "b"], found ".md"`},
+		{`ext in [".md", 1]`, `column 16: cannot look for a string in a list holding a number`},
+		{`ext in [user]`, `column 9: lists can only hold literals`},
+		{`ext in [".md" ".txt"]`, `column 15: expected , or ] in list, found ".txt"`},
+		{`size matches "1*"`, `column 6: matches needs a string on the left, not a number`},
+		{`ext matches ext`, `column 13: matches needs a quoted pattern, found "ext"`},
+		{`name =~ "(a"`, `column 9: invalid pattern "(a": error parsing regexp: missing closing ): ` + "`(a`"},
+		{`user == "a" $`, `column 13: unexpected character '$'`},
+	}
+	for _, c := range cases {
+		_, err := CompileFilter(c.expr)
+		if err == nil {
+			t.Errorf("CompileFilter(%q): expected an error", c.expr)
+			continue
+		}
+		if err.Error() != c.want {
+			t.Errorf("CompileFilter(%q):\n got %s\nwant %s", c.expr, err, c.want)
+		}
+	}
+}
+
+func TestCallbackFilters(t *testing.T) {
+	doc, err := yamledit.Parse([]byte(`callbacks:
+  - name: "images"
+    events: ["file.write"]
+    timing: "pre"
+    target: {type: "directory", path: "/"}
+    endpoints: []
+    when: mime matches "image/*" && size > 1MB
+  - name: "all"
+    events: ["file.write"]
+    timing: "pre"
+    target: {type: "directory", path: "/"}
+    endpoints: []
+  - name: "broken"
+    events: ["file.write"]
+    timing: "pre"
+    target: {type: "directory", path: "/"}
+    endpoints: []
+    when: size > "1MB"
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	_, err = ProcessCallbacks(doc, "callbacks", &Variables{})
+	if !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+	if want := `invalid when expression for callback "broken" at line 18, column 11: column 6: cannot compare a number with a string`; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+
+	callbacksNode := mappingValue(doc.Content[0], "callbacks")
+	callbacksNode.Content = callbacksNode.Content[:2]
+	callbacks, err := ProcessCallbacks(doc, "callbacks", &Variables{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := NewMatcher(callbacks)
+	if got := names(m.MatchContext("pre", EventContext{Event: "file.write", Path: "/a.png", MIME: "image/png", Size: 2 << 20})); got != "images all" {
+		t.Errorf("expected images all, got %s", got)
+	}
+	if got := names(m.MatchContext("pre", EventContext{Event: "file.write", Path: "/a.png", MIME: "image/png", Size: 100})); got != "all" {
+		t.Errorf("expected all for a small image, got %s", got)
+	}
+	if got := names(m.Match("file.write", "pre", "/a.png")); got != "all" {
+		t.Errorf("expected Match to evaluate filters without the other facts, got %s", got)
+	}
+}
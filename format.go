@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropsite-ai/yamledit"
+	"gopkg.in/yaml.v3"
+)
+
+// Format identifies the file format of a configuration file.
+type Format int
+
+const (
+	// FormatAuto detects the format from the file extension, defaulting to YAML.
+	FormatAuto Format = iota
+	// FormatYAML is YAML.
+	FormatYAML
+	// FormatJSON is JSON.
+	FormatJSON
+	// FormatTOML is TOML.
+	FormatTOML
+)
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case FormatYAML:
+		return "YAML"
+	case FormatJSON:
+		return "JSON"
+	case FormatTOML:
+		return "TOML"
+	}
+	return "auto"
+}
+
+// FormatFromPath returns the format implied by the extension of path:
+// FormatJSON for ".json", FormatTOML for ".toml" and FormatYAML otherwise.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// WithFormat sets the format of the file passed to Load or Save instead of
+// detecting it from the file extension. Included files are always detected
+// from their own extension.
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// resolve returns f, or the format detected from path if f is FormatAuto.
+func (f Format) resolve(path string) Format {
+	if f == FormatAuto {
+		return FormatFromPath(path)
+	}
+	return f
+}
+
+// parse converts data in the given format into a YAML document node.
+func parse(data []byte, format Format) (*yaml.Node, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatTOML:
+		return parseTOML(data)
+	case FormatYAML, FormatAuto:
+		return yamledit.Parse(data)
+	}
+	return nil, fmt.Errorf("unsupported format %v", format)
+}
+
+// encode converts a YAML document node into the given format.
+func encode(doc *yaml.Node, format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return encodeJSON(doc)
+	case FormatTOML:
+		return encodeTOML(doc)
+	case FormatYAML, FormatAuto:
+		return yamledit.Encode(doc)
+	}
+	return nil, fmt.Errorf("unsupported format %v", format)
+}
+
+// documentRoot returns the root node of a document, following aliases.
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil
+		}
+		doc = doc.Content[0]
+	}
+	return resolveAlias(doc)
+}
+
+// resolveAlias returns the node an alias node points to.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want Format
+	}{
+		{"config.yaml", FormatYAML},
+		{"config.yml", FormatYAML},
+		{"config.json", FormatJSON},
+		{"CONFIG.JSON", FormatJSON},
+		{"config.toml", FormatTOML},
+		{"config", FormatYAML},
+	}
+	for _, c := range cases {
+		if got := FormatFromPath(c.path); got != c.want {
+			t.Errorf("FormatFromPath(%q) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{
+  "variables": {
+    "endpoints": {"service1": "http://example.com"},
+    "secrets": {"secret1": "", "secret2": "existingsecret"},
+    "users": {"user1": "root"}
+  },
+  "server": {"port": 8080, "debug": false, "ratio": 0.5},
+  "callbacks": [
+    {
+      "name": "callback1",
+      "events": ["event1", "event2"],
+      "timing": "pre",
+      "target": {"type": "file", "path": "some/path"},
+      "endpoints": ["service1"]
+    }
+  ]
+}
+`,
+		"config.toml": `
+[variables.endpoints]
+service1 = "http://example.com"
+
+[variables.secrets]
+secret1 = ""
+secret2 = "existingsecret"
+
+[variables.users]
+user1 = "root"
+
+[server]
+port = 8080
+debug = false
+ratio = 0.5
+
+[[callbacks]]
+name = "callback1"
+events = ["event1", "event2"]
+timing = "pre"
+endpoints = ["service1"]
+
+[callbacks.target]
+type = "file"
+path = "some/path"
+`,
+	}
+
+	for name := range files {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{name: files[name]})
+			path := filepath.Join(dir, name)
+
+			doc, vars, callbacks, err := Load(path, nil)
+			if err != nil {
+				t.Fatalf("Load returned error: %v", err)
+			}
+			if vars.Endpoints["service1"] != "http://example.com" || vars.Users["user1"] != "root" {
+				t.Errorf("unexpected variables: %+v", vars)
+			}
+			secret1 := vars.Secrets["secret1"]
+			if len(secret1) != 64 {
+				t.Errorf("expected secret1 to be generated, got %q", secret1)
+			}
+			if len(callbacks) != 1 || callbacks[0].Target.Path != "some/path" || len(callbacks[0].Events) != 2 {
+				t.Errorf("unexpected callbacks: %+v", callbacks)
+			}
+
+			// Saving writes the original format back, including the generated secret.
+			if err := Save(path, doc); err != nil {
+				t.Fatalf("Save returned error: %v", err)
+			}
+			_, reloaded, _, err := Load(path, nil)
+			if err != nil {
+				t.Fatalf("failed to reload saved file: %v\n%s", err, readFile(t, path))
+			}
+			if reloaded.Secrets["secret1"] != secret1 {
+				t.Errorf("expected saved secret1 %q, got %q", secret1, reloaded.Secrets["secret1"])
+			}
+			if FormatFromPath(path) == FormatJSON {
+				if _, err := parseJSON([]byte(readFile(t, path))); err != nil {
+					t.Errorf("saved file is not JSON: %v", err)
+				}
+			}
+
+			var port int
+			var debug bool
+			var ratio float64
+			if err := yamledit.ReadNode(doc, "server.port", &port); err != nil || port != 8080 {
+				t.Errorf("expected server.port 8080, got %d (%v)", port, err)
+			}
+			if err := yamledit.ReadNode(doc, "server.debug", &debug); err != nil || debug {
+				t.Errorf("expected server.debug false, got %v (%v)", debug, err)
+			}
+			if err := yamledit.ReadNode(doc, "server.ratio", &ratio); err != nil || ratio != 0.5 {
+				t.Errorf("expected server.ratio 0.5, got %v (%v)", ratio, err)
+			}
+		})
+	}
+}
+
+func TestSaveWithFormat(t *testing.T) {
+	doc, err := yamledit.Parse([]byte("name: \"a \\\"quoted\\\" value\"\nlist: [1, 2]\n"))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.conf")
+	if err := Save(path, doc, WithFormat(FormatTOML)); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	want := "name = \"a \\\"quoted\\\" value\"\nlist = [1, 2]\n"
+	if got := readFile(t, path); got != want {
+		t.Errorf("expected TOML\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestParseJSONPositions(t *testing.T) {
+	doc, err := parseJSON([]byte("{\n  \"a\": {\n    \"b\": \"c\"\n  }\n}"))
+	if err != nil {
+		t.Fatalf("parseJSON returned error: %v", err)
+	}
+	b := doc.Content[0].Content[1].Content[1]
+	if b.Line != 3 || b.Column != 10 {
+		t.Errorf("expected value at 3:10, got %d:%d", b.Line, b.Column)
+	}
+}
+
+// readFile returns the contents of the file at path.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
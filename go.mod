@@ -3,6 +3,7 @@ module github.com/dropsite-ai/config
 go 1.23.5
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/dropsite-ai/yamledit v0.0.0-20250219061447-fc655270f08c
 	gopkg.in/yaml.v3 v3.0.1
 )
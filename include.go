@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey is the top-level key listing files to compose into a document.
+const includeKey = "include"
+
+// parseFile reads and parses the file at path from fsys (or the OS file system
+// if fsys is nil), in the format given by its extension, and resolves its includes.
+// chain holds the canonical paths of the files currently being included.
+func parseFile(fsys fs.FS, path string, m *merger, chain []string) (*yaml.Node, error) {
+	data, err := readConfigFile(fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	doc, err := parse(data, FormatFromPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	m.src.record(doc, path)
+	return resolveIncludes(fsys, doc, path, m, chain)
+}
+
+// resolveIncludes loads the files listed under the top-level include key of doc,
+// relative to the directory of path in fsys, and deep-merges doc on top of them with m.
+// Later includes override earlier ones. The include key is removed from the result.
+func resolveIncludes(fsys fs.FS, doc *yaml.Node, path string, m *merger, chain []string) (*yaml.Node, error) {
+	abs, err := canonicalPath(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range chain {
+		if p == abs {
+			return nil, fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(chain[i:], abs), " -> "))
+		}
+	}
+	chain = append(chain, abs)
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return doc, nil
+	}
+	root := doc.Content[0]
+	includeNode := mappingValue(root, includeKey)
+	if includeNode == nil {
+		return doc, nil
+	}
+	var includes []string
+	if includeNode.Kind == yaml.ScalarNode {
+		includes = []string{includeNode.Value}
+	} else if err := includeNode.Decode(&includes); err != nil {
+		return nil, fmt.Errorf("invalid %s%s: expected a file name or a list of file names", includeKey, m.src.in(includeNode))
+	}
+
+	// Drop the include key so it does not end up in the merged document.
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == includeKey {
+			root.Content = append(root.Content[:i:i], root.Content[i+2:]...)
+			break
+		}
+	}
+
+	var merged *yaml.Node
+	for _, include := range includes {
+		include = includePath(fsys, path, include)
+		included, err := parseFile(fsys, include, m, chain)
+		if err != nil {
+			return nil, fmt.Errorf("including %s from %s: %w", include, path, err)
+		}
+		merged = m.merge(merged, included)
+	}
+	return m.merge(merged, doc), nil
+}
+
+// readConfigFile reads name from fsys, or from the OS file system if fsys is nil.
+func readConfigFile(fsys fs.FS, name string) ([]byte, error) {
+	if fsys == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+// includePath returns the path of include relative to the file at from.
+func includePath(fsys fs.FS, from, include string) string {
+	if fsys != nil {
+		return path.Join(path.Dir(from), include)
+	}
+	if filepath.IsAbs(include) {
+		return include
+	}
+	return filepath.Join(filepath.Dir(from), include)
+}
+
+// canonicalPath returns the path identifying name when detecting include cycles.
+func canonicalPath(fsys fs.FS, name string) (string, error) {
+	if fsys != nil {
+		return path.Clean(name), nil
+	}
+	return filepath.Abs(name)
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+// writeFiles writes each name/content pair into dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadIncludes(t *testing.T) {
+	t.Run("Included files are merged underneath", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"config.yaml": `
+include: [base.yaml, shared/secrets.yaml]
+variables:
+  endpoints:
+    service1: "http://override.example.com"
+`,
+			"base.yaml": `
+server:
+  port: 8080
+variables:
+  endpoints:
+    service1: "http://example.com"
+    service2: "http://example.org"
+callbacks:
+  - name: "callback1"
+    events: ["event1"]
+    timing: "pre"
+    target:
+      type: "file"
+      path: "some/path"
+    endpoints: ["service2"]
+`,
+			"shared/secrets.yaml": `
+variables:
+  secrets:
+    secret1: "fromfile"
+`,
+		})
+
+		doc, vars, callbacks, err := Load(filepath.Join(dir, "config.yaml"), nil)
+		if err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+		if vars.Endpoints["service1"] != "http://override.example.com" {
+			t.Errorf("expected including file to override service1, got %q", vars.Endpoints["service1"])
+		}
+		if vars.Endpoints["service2"] != "http://example.org" {
+			t.Errorf("expected service2 from base.yaml, got %q", vars.Endpoints["service2"])
+		}
+		if vars.Secrets["secret1"] != "fromfile" {
+			t.Errorf("expected secret1 from shared/secrets.yaml, got %q", vars.Secrets["secret1"])
+		}
+		if len(callbacks) != 1 {
+			t.Errorf("expected 1 callback from base.yaml, got %d", len(callbacks))
+		}
+		var port int
+		if err := yamledit.ReadNode(doc, "server.port", &port); err != nil || port != 8080 {
+			t.Errorf("expected server.port 8080 in merged document, got %d (%v)", port, err)
+		}
+		var include []string
+		if err := yamledit.ReadNode(doc, "include", &include); err == nil {
+			t.Errorf("expected include key to be removed from merged document, got %v", include)
+		}
+
+		// Saving the merged document writes one flattened file.
+		flat := filepath.Join(dir, "flat.yaml")
+		if err := Save(flat, doc); err != nil {
+			t.Fatalf("Save returned error: %v", err)
+		}
+		saved := readFile(t, flat)
+		for _, want := range []string{"port: 8080", `secret1: "fromfile"`} {
+			if !regexp.MustCompile(regexp.QuoteMeta(want)).MatchString(saved) {
+				t.Errorf("expected saved file to contain %q, got:\n%s", want, saved)
+			}
+		}
+		if regexp.MustCompile(`(?m)^include:`).MatchString(saved) {
+			t.Errorf("expected saved file to have no include key, got:\n%s", saved)
+		}
+	})
+
+	t.Run("Include cycle", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"a.yaml": "include: b.yaml\n",
+			"b.yaml": "include: a.yaml\n",
+		})
+		_, _, _, err := Load(filepath.Join(dir, "a.yaml"), nil)
+		if err == nil {
+			t.Fatal("expected error due to include cycle, got nil")
+		}
+		if !regexp.MustCompile(`include cycle: .*a\.yaml -> .*b\.yaml -> .*a\.yaml`).MatchString(err.Error()) {
+			t.Errorf("expected error message to name the cycle, got %v", err)
+		}
+	})
+
+	t.Run("Errors name the included file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"config.yaml": "include: [users.yaml]\n",
+			"users.yaml": `
+variables:
+  users:
+    user1: "Not Valid"
+`,
+		})
+		_, _, _, err := Load(filepath.Join(dir, "config.yaml"), nil)
+		if err == nil {
+			t.Fatal("expected error due to invalid username, got nil")
+		}
+		if !regexp.MustCompile(`invalid username for "user1" in .*users\.yaml`).MatchString(err.Error()) {
+			t.Errorf("expected error message to name users.yaml, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// referenceRegex matches ${section.key} references inside variable values, and
+// $${...} escapes standing for a literal ${...}.
+var referenceRegex = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// interpolator resolves ${section.key} references between variables,
+// detecting reference cycles and references to missing keys.
+type interpolator struct {
+	raw      map[string]string // "section.key" -> value as written
+	resolved map[string]string // "section.key" -> value with references replaced
+	chain    []string          // references currently being resolved
+}
+
+// newInterpolator returns an interpolator over the given sections.
+func newInterpolator(sections map[string]map[string]string) *interpolator {
+	in := &interpolator{
+		raw:      make(map[string]string),
+		resolved: make(map[string]string),
+	}
+	for section, values := range sections {
+		for key, value := range values {
+			in.raw[section+"."+key] = value
+		}
+	}
+	return in
+}
+
+// resolve returns the value of ref with all nested references replaced.
+func (in *interpolator) resolve(ref string) (string, error) {
+	if value, ok := in.resolved[ref]; ok {
+		return value, nil
+	}
+	for i, r := range in.chain {
+		if r == ref {
+			return "", fmt.Errorf("%w: %s", ErrReferenceCycle, strings.Join(append(in.chain[i:], ref), " -> "))
+		}
+	}
+	raw, ok := in.raw[ref]
+	if !ok {
+		if len(in.chain) == 0 {
+			return "", fmt.Errorf("%w %q", ErrUnknownReference, ref)
+		}
+		return "", fmt.Errorf("%w %q (%s -> %s)", ErrUnknownReference, ref, strings.Join(in.chain, " -> "), ref)
+	}
+
+	in.chain = append(in.chain, ref)
+	value, err := in.expand(raw)
+	in.chain = in.chain[:len(in.chain)-1]
+	if err != nil {
+		return "", err
+	}
+	in.resolved[ref] = value
+	return value, nil
+}
+
+// expand replaces every ${section.key} reference in s with its resolved value, and
+// every $${...} escape with ${...}.
+func (in *interpolator) expand(s string) (string, error) {
+	matches := referenceRegex.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		last = m[1]
+		if strings.HasPrefix(s[m[0]:], "$$") {
+			b.WriteString(s[m[0]+1 : m[1]])
+			continue
+		}
+		value, err := in.resolve(strings.TrimSpace(s[m[2]:m[3]]))
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// Interpolate replaces ${section.key} references in s (for example
+// "${paths.root}/data" or "${endpoints.api}/hooks") with the processed values.
+// $${ stands for a literal ${. A nil Variables has no values.
+func (v *Variables) Interpolate(s string) (string, error) {
+	in := newInterpolator(v.sections())
+	// Processed values are already resolved: a ${ left in them came from an escape.
+	in.resolved = in.raw
+	return in.expand(s)
+}
+
+// sections returns the variable maps keyed by their section name.
+func (v *Variables) sections() map[string]map[string]string {
+	if v == nil {
+		return nil
+	}
+	return map[string]map[string]string{
+		"endpoints": v.Endpoints,
+		"secrets":   v.Secrets,
+		"users":     v.Users,
+		"paths":     v.Paths,
+	}
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestProcessVariablesInterpolation(t *testing.T) {
+	t.Run("References are resolved across sections", func(t *testing.T) {
+		yamlStr := `
+variables:
+  endpoints:
+    api: "http://example.com/api"
+    hooks: "${endpoints.api}/hooks"
+  users:
+    owner: "${users.admin}"
+    admin: "root"
+  paths:
+    root: "/srv/llmfs"
+    data: "${paths.root}/data"
+    cache: "${paths.data}/cache"
+`
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+			t.Fatalf("failed to unmarshal YAML: %v", err)
+		}
+		vars, err := ProcessVariables(&doc, "variables")
+		if err != nil {
+			t.Fatalf("ProcessVariables returned error: %v", err)
+		}
+		if got := vars.Endpoints["hooks"]; got != "http://example.com/api/hooks" {
+			t.Errorf("expected hooks endpoint to be resolved, got %q", got)
+		}
+		if got := vars.Users["owner"]; got != "root" {
+			t.Errorf("expected owner to be resolved, got %q", got)
+		}
+		if got := vars.Paths["cache"]; got != filepath.Join("/srv/llmfs", "data", "cache") {
+			t.Errorf("expected cache path to be resolved, got %q", got)
+		}
+	})
+
+	t.Run("Reference cycle", func(t *testing.T) {
+		yamlStr := `
+variables:
+  paths:
+    a: "${paths.b}/x"
+    b: "${paths.c}/y"
+    c: "${paths.a}/z"
+`
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+			t.Fatalf("failed to unmarshal YAML: %v", err)
+		}
+		_, err := ProcessVariables(&doc, "variables")
+		if err == nil {
+			t.Fatal("expected error due to reference cycle, got nil")
+		}
+		if !regexp.MustCompile(`reference cycle: paths.a -> paths.b -> paths.c -> paths.a`).MatchString(err.Error()) {
+			t.Errorf("expected error message to name the cycle, got %v", err)
+		}
+	})
+
+	t.Run("Unknown reference", func(t *testing.T) {
+		yamlStr := `
+variables:
+  paths:
+    a: "${paths.b}/x"
+    b: "${paths.missing}/y"
+`
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+			t.Fatalf("failed to unmarshal YAML: %v", err)
+		}
+		_, err := ProcessVariables(&doc, "variables")
+		if err == nil {
+			t.Fatal("expected error due to unknown reference, got nil")
+		}
+		if !regexp.MustCompile(`unknown reference "paths.missing" \(paths.a -> paths.b -> paths.missing\)`).MatchString(err.Error()) {
+			t.Errorf("expected error message to name the chain, got %v", err)
+		}
+	})
+}
+
+func TestProcessCallbacksInterpolation(t *testing.T) {
+	yamlStr := `
+callbacks:
+  - name: "callback1"
+    events: ["event1"]
+    timing: "pre"
+    target:
+      type: "directory"
+      path: "${paths.root}/docs"
+    endpoints: []
+  - name: "callback2"
+    events: ["event1"]
+    timing: "pre"
+    target:
+      type: "directory"
+      path: "${paths.missing}/docs"
+    endpoints: []
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+		t.Fatalf("failed to unmarshal YAML: %v", err)
+	}
+	vars := &Variables{
+		Paths: map[string]string{"root": "/srv"},
+	}
+	_, err := ProcessCallbacks(&doc, "callbacks", vars)
+	if err == nil {
+		t.Fatal("expected error due to unknown reference in target path, got nil")
+	}
+	if !regexp.MustCompile(`invalid target path for callback "callback2"`).MatchString(err.Error()) {
+		t.Errorf("expected error message to mention the callback, got %v", err)
+	}
+
+	// Drop the broken callback and check the path is resolved.
+	doc.Content[0].Content[1].Content = doc.Content[0].Content[1].Content[:1]
+	callbacks, err := ProcessCallbacks(&doc, "callbacks", vars)
+	if err != nil {
+		t.Fatalf("ProcessCallbacks returned error: %v", err)
+	}
+	if callbacks[0].Target.Path != "/srv/docs" {
+		t.Errorf("expected target path to be resolved, got %q", callbacks[0].Target.Path)
+	}
+}
+
+func TestInterpolationEscapes(t *testing.T) {
+	yamlStr := `
+variables:
+  secrets:
+    pw: "a$${b}c"
+    copy: "${secrets.pw}-$${secrets.pw}"
+  paths:
+    root: "/srv/$${x}"
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlStr), &doc); err != nil {
+		t.Fatalf("failed to unmarshal YAML: %v", err)
+	}
+	vars, err := ProcessVariables(&doc, "variables")
+	if err != nil {
+		t.Fatalf("ProcessVariables returned error: %v", err)
+	}
+	if got := vars.Secrets["pw"]; got != "a${b}c" {
+		t.Errorf("expected a${b}c, got %q", got)
+	}
+	if got := vars.Secrets["copy"]; got != "a${b}c-${secrets.pw}" {
+		t.Errorf("expected escapes not to be resolved again, got %q", got)
+	}
+	if got := vars.Paths["root"]; got != "/srv/${x}" {
+		t.Errorf("expected /srv/${x}, got %q", got)
+	}
+	if got, err := vars.Interpolate("$${paths.root} is ${paths.root}"); err != nil || got != "${paths.root} is /srv/${x}" {
+		t.Errorf("unexpected interpolation %q (%v)", got, err)
+	}
+}
+
+func TestVariablesInterpolate(t *testing.T) {
+	vars := &Variables{
+		Endpoints: map[string]string{"api": "http://example.com"},
+	}
+	got, err := vars.Interpolate("${endpoints.api}/hooks")
+	if err != nil {
+		t.Fatalf("Interpolate returned error: %v", err)
+	}
+	if got != "http://example.com/hooks" {
+		t.Errorf("expected %q, got %q", "http://example.com/hooks", got)
+	}
+	if _, err := vars.Interpolate("${endpoints.nope}"); err == nil {
+		t.Error("expected error for unknown reference, got nil")
+	}
+}
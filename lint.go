@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Warning describes a likely mistake that does not make a configuration invalid.
+type Warning struct {
+	Callbacks []string // names of the callbacks involved
+	Message   string
+}
+
+// String returns the warning message.
+func (w Warning) String() string {
+	return w.Message
+}
+
+// LintCallbacks looks for likely mistakes in processed callbacks. It warns when two or
+// more callbacks have the same events (in any order), timing, target and when
+// expression, since each of them fires on exactly the same operations and may call
+// the same webhook twice. Disabled callbacks are ignored. The warnings are returned
+// in the order the first callback of each group appears.
+func LintCallbacks(callbacks []CallbackDefinition) []Warning {
+	groups := make(map[string][]int)
+	var order []string
+	for i, cb := range callbacks {
+		if !cb.IsEnabled() {
+			continue
+		}
+		events := slices.Clone(cb.Events)
+		slices.Sort(events)
+		events = slices.Compact(events)
+		key := strings.Join([]string{cb.Timing, cb.Target.Type, cb.Target.Path, cb.When, strings.Join(events, "\x00")}, "\x01")
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var warnings []Warning
+	for _, key := range order {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		first := callbacks[group[0]]
+		names := make([]string, len(group))
+		quoted := make([]string, len(group))
+		for i, idx := range group {
+			names[i] = callbacks[idx].Name
+			quoted[i] = fmt.Sprintf("%q", names[i])
+		}
+		warnings = append(warnings, Warning{
+			Callbacks: names,
+			Message: fmt.Sprintf("callbacks %s have the same events %v, timing %q and target %s %q",
+				strings.Join(quoted, ", "), first.Events, first.Timing, first.Target.Type, first.Target.Path),
+		})
+	}
+	return warnings
+}
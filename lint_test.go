@@ -0,0 +1,34 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintCallbacks(t *testing.T) {
+	target := CallbackTarget{Type: "file", Path: "/data"}
+	callbacks := []CallbackDefinition{
+		{Name: "audit", Events: []string{"write", "delete"}, Timing: "post", Target: target},
+		{Name: "backup", Events: []string{"delete", "write"}, Timing: "post", Target: target},
+		{Name: "guard", Events: []string{"write", "delete"}, Timing: "pre", Target: target},
+		{Name: "other", Events: []string{"write"}, Timing: "post", Target: target},
+		{Name: "elsewhere", Events: []string{"write", "delete"}, Timing: "post", Target: CallbackTarget{Type: "file", Path: "/tmp"}},
+		{Name: "audit-copy", Events: []string{"write", "delete", "write"}, Timing: "post", Target: target},
+	}
+
+	warnings := LintCallbacks(callbacks)
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %v", warnings)
+	}
+	if want := []string{"audit", "backup", "audit-copy"}; !reflect.DeepEqual(warnings[0].Callbacks, want) {
+		t.Errorf("expected callbacks %v, got %v", want, warnings[0].Callbacks)
+	}
+	want := `callbacks "audit", "backup", "audit-copy" have the same events [write delete], timing "post" and target file "/data"`
+	if warnings[0].String() != want {
+		t.Errorf("expected %q, got %q", want, warnings[0].String())
+	}
+
+	if warnings := LintCallbacks(callbacks[2:5]); len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+}
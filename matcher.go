@@ -0,0 +1,123 @@
+package config
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Matcher answers which callbacks apply to an event on a path. It indexes callbacks
+// by timing and target path, so a lookup only visits the callbacks targeting the path
+// or one of its parent directories, which keeps it fast with thousands of callbacks.
+// Glob and regex targets are checked one by one, after a prefix check for globs.
+// A Matcher is safe for concurrent use.
+type Matcher struct {
+	callbacks []CallbackDefinition
+	filters   []*Filter // compiled when expressions of the callbacks, nil if none
+	timings   map[string]*pathIndex
+}
+
+// pathIndex holds the callbacks of one timing by target path.
+type pathIndex struct {
+	files    map[string][]int // exact paths
+	dirs     map[string][]int // directories, matching themselves and their descendants
+	patterns []patternTarget  // glob and regex targets, checked one by one
+}
+
+// patternTarget is a glob or regex target of the callback at index.
+type patternTarget struct {
+	index  int
+	prefix string // literal prefix every matching path has
+	match  func(string) bool
+}
+
+// NewMatcher builds a Matcher from processed callbacks, such as those returned by Load.
+// Disabled callbacks, and callbacks whose when expression does not compile, are left
+// out.
+func NewMatcher(callbacks []CallbackDefinition) *Matcher {
+	m := &Matcher{timings: make(map[string]*pathIndex)}
+	for _, cb := range callbacks {
+		if cb.IsEnabled() {
+			m.callbacks = append(m.callbacks, cb)
+		}
+	}
+	sortCallbacks(m.callbacks)
+	m.filters = make([]*Filter, len(m.callbacks))
+	for i, cb := range m.callbacks {
+		if cb.When != "" {
+			// Filters were validated when the callbacks were processed.
+			filter, err := CompileFilter(cb.When)
+			if err != nil {
+				continue
+			}
+			m.filters[i] = filter
+		}
+		idx := m.timings[cb.Timing]
+		if idx == nil {
+			idx = &pathIndex{files: make(map[string][]int), dirs: make(map[string][]int)}
+			m.timings[cb.Timing] = idx
+		}
+		p := path.Clean(cb.Target.Path)
+		switch cb.Target.Type {
+		case "file":
+			idx.files[p] = append(idx.files[p], i)
+		case "directory":
+			idx.dirs[p] = append(idx.dirs[p], i)
+		case "glob", "regex":
+			// Patterns were validated when the callbacks were processed.
+			if match, err := compileTarget(cb.Target); err == nil && match != nil {
+				pt := patternTarget{index: i, match: match}
+				if cb.Target.Type == "glob" {
+					pt.prefix = globPrefix(cb.Target.Path)
+				}
+				idx.patterns = append(idx.patterns, pt)
+			}
+		}
+	}
+	return m
+}
+
+// Match returns the callbacks with the given timing ("pre" or "post") whose events
+// match event (see MatchEvent) and whose target is the file at p, a directory
+// containing p or p itself, or a glob or regex pattern matching p. The callbacks
+// are returned by decreasing priority, then in the order they were defined.
+// When expressions are evaluated with only the event and path known; use
+// MatchContext to provide the other facts.
+func (m *Matcher) Match(event, timing, p string) []CallbackDefinition {
+	return m.MatchContext(timing, EventContext{Event: event, Path: p})
+}
+
+// MatchContext is like Match for the event and path of ctx, and also leaves out the
+// callbacks whose when expression does not hold for ctx.
+func (m *Matcher) MatchContext(timing string, ctx EventContext) []CallbackDefinition {
+	event, p := ctx.Event, ctx.Path
+	idx := m.timings[timing]
+	if idx == nil {
+		return nil
+	}
+	p = path.Clean(p)
+	candidates := slices.Clone(idx.files[p])
+	for dir := p; ; dir = path.Dir(dir) {
+		candidates = append(candidates, idx.dirs[dir]...)
+		if parent := path.Dir(dir); parent == dir {
+			break
+		}
+	}
+	for _, pt := range idx.patterns {
+		if strings.HasPrefix(p, pt.prefix) && pt.match(p) {
+			candidates = append(candidates, pt.index)
+		}
+	}
+	slices.Sort(candidates)
+
+	var matched []CallbackDefinition
+	for _, i := range candidates {
+		if f := m.filters[i]; f != nil && !f.Match(ctx) {
+			continue
+		}
+		if slices.ContainsFunc(m.callbacks[i].Events, func(pattern string) bool { return MatchEvent(pattern, event) }) {
+			matched = append(matched, m.callbacks[i])
+		}
+	}
+	return matched
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	callbacks := []CallbackDefinition{
+		{Name: "readme", Events: []string{"file.write"}, Timing: "pre", Target: CallbackTarget{Type: "file", Path: "/projects/a/README.md"}},
+		{Name: "projects", Events: []string{"file.*"}, Timing: "pre", Target: CallbackTarget{Type: "directory", Path: "/projects/"}},
+		{Name: "project-a", Events: []string{"file.delete", "file.write"}, Timing: "pre", Target: CallbackTarget{Type: "directory", Path: "/projects/a"}},
+		{Name: "post", Events: []string{"*"}, Timing: "post", Target: CallbackTarget{Type: "directory", Path: "/"}},
+		{Name: "sibling", Events: []string{"*"}, Timing: "pre", Target: CallbackTarget{Type: "directory", Path: "/projects/ab"}},
+	}
+	m := NewMatcher(callbacks)
+
+	cases := []struct {
+		event, timing, path string
+		want                []string
+	}{
+		{"file.write", "pre", "/projects/a/README.md", []string{"readme", "projects", "project-a"}},
+		{"file.read", "pre", "/projects/a/README.md", []string{"projects"}},
+		{"file.delete", "pre", "/projects/a/docs/x.md", []string{"projects", "project-a"}},
+		{"file.delete", "pre", "/projects/a", []string{"projects", "project-a"}},
+		{"file.write", "pre", "/projects/ab/x", []string{"projects", "sibling"}},
+		{"file.write", "pre", "/other/x", nil},
+		{"app.deploy", "post", "/anything", []string{"post"}},
+		{"file.write", "during", "/projects/a/README.md", nil},
+		{"file.write", "pre", "/projects/a/./docs/../README.md", []string{"readme", "projects", "project-a"}},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s %s %s", c.timing, c.event, c.path), func(t *testing.T) {
+			var names []string
+			for _, cb := range m.Match(c.event, c.timing, c.path) {
+				names = append(names, cb.Name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(c.want) {
+				t.Errorf("expected %v, got %v", c.want, names)
+			}
+		})
+	}
+
+	t.Run("Thousands of callbacks", func(t *testing.T) {
+		var many []CallbackDefinition
+		for i := 0; i < 5000; i++ {
+			many = append(many, CallbackDefinition{
+				Name:   fmt.Sprintf("cb%d", i),
+				Events: []string{"file.write"},
+				Timing: "post",
+				Target: CallbackTarget{Type: "directory", Path: fmt.Sprintf("/users/u%d", i)},
+			})
+		}
+		m := NewMatcher(many)
+		got := m.Match("file.write", "post", "/users/u4321/notes/today.md")
+		if len(got) != 1 || got[0].Name != "cb4321" {
+			t.Errorf("expected only cb4321, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// merger deep-merges YAML nodes while keeping track of their sources.
+type merger struct {
+	src       sources
+	sequences MergeStrategy
+	// rejectDuplicates makes merge record keys that would be overridden in
+	// duplicates instead of silently letting the overlay win.
+	rejectDuplicates bool
+	duplicates       ValidationErrors
+}
+
+// merge deep-merges overlay on top of base and returns the result. Mappings are
+// merged key by key, keeping overlay's keys in their original order followed by
+// the keys only present in base. Sequences are replaced or appended according to
+// the merge strategy, and any other overlay node replaces the base node.
+// Nodes are shared with the inputs rather than copied, so comments, positions and
+// sources are kept.
+func (m *merger) merge(base, overlay *yaml.Node) *yaml.Node {
+	return m.mergeAt(base, overlay, "")
+}
+
+// mergeAt implements merge for the nodes found at the dot-notation path.
+func (m *merger) mergeAt(base, overlay *yaml.Node, path string) *yaml.Node {
+	if base == nil {
+		return overlay
+	}
+	if overlay == nil {
+		return base
+	}
+	if base.Kind == yaml.SequenceNode && overlay.Kind == yaml.SequenceNode && m.sequences == AppendSequences {
+		merged := *overlay
+		merged.Content = append(append([]*yaml.Node{}, base.Content...), overlay.Content...)
+		if file, ok := m.src[overlay]; ok {
+			m.src[&merged] = file
+		}
+		return &merged
+	}
+	if base.Kind != overlay.Kind || (base.Kind != yaml.DocumentNode && base.Kind != yaml.MappingNode) {
+		return overlay
+	}
+	if base.Kind == yaml.DocumentNode && (len(base.Content) == 0 || len(overlay.Content) == 0) {
+		if len(overlay.Content) == 0 {
+			return base
+		}
+		return overlay
+	}
+
+	merged := *overlay
+	merged.Content = nil
+	if file, ok := m.src[overlay]; ok {
+		m.src[&merged] = file
+	}
+
+	if base.Kind == yaml.DocumentNode {
+		merged.Content = []*yaml.Node{m.mergeAt(base.Content[0], overlay.Content[0], path)}
+		return &merged
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		seen[key.Value] = true
+		keyPath := key.Value
+		if path != "" {
+			keyPath = path + "." + key.Value
+		}
+		baseKey, baseValue := mappingEntry(base, key.Value)
+		if m.rejectDuplicates && baseValue != nil && !m.mergeable(baseValue, value) {
+			m.duplicates.add(fmt.Errorf("%w %q%s (already defined%s)", ErrDuplicateKey,
+				keyPath, m.src.in(key), m.src.in(baseKey)))
+		}
+		merged.Content = append(merged.Content, key, m.mergeAt(baseValue, value, keyPath))
+	}
+	for i := 0; i+1 < len(base.Content); i += 2 {
+		if !seen[base.Content[i].Value] {
+			merged.Content = append(merged.Content, base.Content[i], base.Content[i+1])
+		}
+	}
+	return &merged
+}
+
+// mergeable reports whether base and overlay are combined by merge rather than
+// overlay replacing base.
+func (m *merger) mergeable(base, overlay *yaml.Node) bool {
+	if base.Kind != overlay.Kind {
+		return false
+	}
+	return base.Kind == yaml.MappingNode || (base.Kind == yaml.SequenceNode && m.sequences == AppendSequences)
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	_, value := mappingEntry(mapping, key)
+	return value
+}
+
+// mappingEntry returns the key and value nodes for key in a mapping node, or nils.
+func mappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestMerge(t *testing.T) {
+	base, err := yamledit.Parse([]byte(`
+a: 1
+nested:
+  x: base
+  y: base
+list: [1, 2]
+`))
+	if err != nil {
+		t.Fatalf("failed to parse base: %v", err)
+	}
+	overlay, err := yamledit.Parse([]byte(`
+nested:
+  # kept comment
+  y: overlay
+list: [3]
+b: 2
+`))
+	if err != nil {
+		t.Fatalf("failed to parse overlay: %v", err)
+	}
+
+	cases := []struct {
+		strategy MergeStrategy
+		want     string
+	}{
+		{ReplaceSequences, "nested:\n  # kept comment\n  y: overlay\n  x: base\nlist: [3]\nb: 2\na: 1\n"},
+		{AppendSequences, "nested:\n  # kept comment\n  y: overlay\n  x: base\nlist: [1, 2, 3]\nb: 2\na: 1\n"},
+	}
+	for _, c := range cases {
+		m := &merger{src: sources{}, sequences: c.strategy}
+		out, err := yamledit.Encode(m.merge(base, overlay))
+		if err != nil {
+			t.Fatalf("failed to encode merged document: %v", err)
+		}
+		if string(out) != c.want {
+			t.Errorf("strategy %d: expected\n%s\ngot\n%s", c.strategy, c.want, out)
+		}
+	}
+}
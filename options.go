@@ -0,0 +1,69 @@
+package config
+
+// Option configures how configuration files are loaded and processed.
+type Option func(*options)
+
+// options holds the settings applied by Option values.
+type options struct {
+	sequences     MergeStrategy
+	mergeDefaults bool
+	format        Format
+	schemas       []*Schema
+	strict        bool
+	events        *EventCatalog
+}
+
+// newOptions applies opts on top of the defaults.
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// MergeStrategy controls how sequences are combined when documents are merged.
+type MergeStrategy int
+
+const (
+	// ReplaceSequences replaces a sequence with the one from the later document.
+	ReplaceSequences MergeStrategy = iota
+	// AppendSequences appends the items of the later sequence to the earlier one.
+	AppendSequences
+)
+
+// WithSequenceMerge sets how sequences are merged across layers and includes.
+// The default is ReplaceSequences.
+func WithSequenceMerge(strategy MergeStrategy) Option {
+	return func(o *options) {
+		o.sequences = strategy
+	}
+}
+
+// WithDefaultsMerge makes Load deep-merge the default YAML underneath an existing
+// file instead of only using it when the file is missing, so keys added to the
+// defaults are filled in for existing installations.
+func WithDefaultsMerge() Option {
+	return func(o *options) {
+		o.mergeDefaults = true
+	}
+}
+
+// WithSchema validates the loaded document against schema before its variables and
+// callbacks are processed; loading fails with a *SchemaError listing every violation.
+// It may be given more than once, for example together with BuiltinSchema.
+func WithSchema(schema *Schema) Option {
+	return func(o *options) {
+		o.schemas = append(o.schemas, schema)
+	}
+}
+
+// WithStrict rejects keys that are not fields of CallbackDefinition or CallbackTarget
+// and subsections of variables other than endpoints, secrets, users and paths, which
+// are otherwise silently ignored. Each unknown key is reported with the nearest known
+// key as a suggestion.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
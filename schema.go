@@ -0,0 +1,708 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed schema.json
+var builtinSchemaJSON []byte
+
+// Schema is a compiled JSON Schema used to validate a whole configuration document.
+//
+// The supported keywords are type, enum, const, properties, required,
+// additionalProperties, patternProperties, minProperties, maxProperties, items,
+// minItems, maxItems, uniqueItems, minLength, maxLength, pattern, format, minimum,
+// maximum, exclusiveMinimum, exclusiveMaximum, multipleOf, allOf, anyOf, oneOf,
+// not, and $ref to a JSON pointer within the same schema (such as "#/$defs/name").
+// The annotations $schema, $id, $comment, $defs, definitions, title, description,
+// default, examples, deprecated, readOnly and writeOnly are accepted; CompileSchema
+// rejects any other keyword rather than ignoring a constraint. The formats checked
+// are "uri", "username", "duration" and "regex"; strings holding ${...} references
+// are not format-checked, since references are only resolved during processing.
+//
+// A compiled Schema is never modified, so it is safe for concurrent use.
+type Schema struct {
+	root *schemaNode
+}
+
+// schemaCompiler compiles the subschemas of one raw schema by JSON pointer.
+type schemaCompiler struct {
+	raw   interface{}
+	cache map[string]*schemaNode
+	refs  []*schemaNode // nodes whose $ref is not resolved yet
+}
+
+// schemaNode is one compiled (sub)schema.
+type schemaNode struct {
+	always     *bool // set for the boolean schemas true and false
+	types      []string
+	enum       []interface{}
+	constValue *interface{}
+
+	properties        map[string]*schemaNode
+	required          []string
+	additional        *schemaNode
+	patternProperties map[*regexp.Regexp]*schemaNode
+	minProperties     *int
+	maxProperties     *int
+
+	items       *schemaNode
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	format    string
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*schemaNode
+	anyOf []*schemaNode
+	oneOf []*schemaNode
+	not   *schemaNode
+	ref   string      // the $ref, if any
+	refTo *schemaNode // the subschema ref points to
+}
+
+// schemaKeywords are the keywords CompileSchema understands: true for the ones it
+// checks, false for annotations that do not constrain documents.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "properties": true, "required": true,
+	"additionalProperties": true, "patternProperties": true, "minProperties": true,
+	"maxProperties": true, "items": true, "minItems": true, "maxItems": true,
+	"uniqueItems": true, "minLength": true, "maxLength": true, "pattern": true,
+	"format": true, "minimum": true, "maximum": true, "exclusiveMinimum": true,
+	"exclusiveMaximum": true, "multipleOf": true, "allOf": true, "anyOf": true,
+	"oneOf": true, "not": true, "$ref": true,
+
+	"$schema": false, "$id": false, "$comment": false, "$defs": false, "definitions": false,
+	"title": false, "description": false, "default": false, "examples": false,
+	"deprecated": false, "readOnly": false, "writeOnly": false,
+}
+
+// CompileSchema compiles a JSON Schema written in JSON or YAML.
+func CompileSchema(data []byte) (*Schema, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+	c := &schemaCompiler{raw: normalize(raw), cache: make(map[string]*schemaNode)}
+	root, err := c.compile(c.raw, "#")
+	if err != nil {
+		return nil, err
+	}
+	// Resolve every $ref now, so that validating never compiles anything. Resolving
+	// may compile subschemas with references of their own.
+	for len(c.refs) > 0 {
+		node := c.refs[0]
+		c.refs = c.refs[1:]
+		if node.refTo, err = c.resolveRef(node.ref); err != nil {
+			return nil, err
+		}
+	}
+	return &Schema{root: root}, nil
+}
+
+// BuiltinSchema returns the schema for the variables and callbacks sections
+// shipped with the package.
+func BuiltinSchema() *Schema {
+	s, err := CompileSchema(builtinSchemaJSON)
+	if err != nil {
+		panic(fmt.Sprintf("compiling built-in schema: %v", err))
+	}
+	return s
+}
+
+// SchemaViolation describes one place where a document does not match a schema.
+type SchemaViolation struct {
+	Path    string // dot-notation path, with [i] for sequence items
+	File    string // set when the document was loaded from a file
+	Line    int
+	Column  int
+	Message string
+
+	node *yaml.Node
+}
+
+// String formats the violation as "path (file:line:column): message", leaving out
+// the parts of the position that are not known.
+func (v SchemaViolation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "document"
+	}
+	if v.File != "" || v.Line > 0 {
+		return fmt.Sprintf("%s (%s): %s", path, position(v.File, v.Line, v.Column), v.Message)
+	}
+	return fmt.Sprintf("%s: %s", path, v.Message)
+}
+
+// SchemaError is returned by Schema.Validate and lists every violation found.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+// Error lists the violations, one per line.
+func (e *SchemaError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = v.String()
+	}
+	return "schema validation failed:\n  " + strings.Join(lines, "\n  ")
+}
+
+// Validate checks doc against the schema and returns a *SchemaError listing
+// every violation, or nil if the document is valid.
+func (s *Schema) Validate(doc *yaml.Node) error {
+	return s.check(doc, nil)
+}
+
+// check implements Validate, naming the source file of each violation.
+func (s *Schema) check(doc *yaml.Node, src sources) error {
+	root := documentRoot(doc)
+	if root == nil {
+		root = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	}
+	violations := s.validate(s.root, root, "")
+	if len(violations) == 0 {
+		return nil
+	}
+	for i := range violations {
+		violations[i].File = src[violations[i].node]
+	}
+	return &SchemaError{Violations: violations}
+}
+
+// compile compiles the raw schema found at the JSON pointer ptr.
+func (s *schemaCompiler) compile(raw interface{}, ptr string) (*schemaNode, error) {
+	if node, ok := s.cache[ptr]; ok {
+		return node, nil
+	}
+	node := &schemaNode{}
+	s.cache[ptr] = node
+
+	if b, ok := raw.(bool); ok {
+		node.always = &b
+		return node, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema at %s must be an object or a boolean", ptr)
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := schemaKeywords[key]; !ok {
+			return nil, fmt.Errorf("unsupported keyword %q at %s", key, ptr)
+		}
+	}
+	// Compile definitions even if nothing refers to them, so that they are checked.
+	for _, key := range []string{"$defs", "definitions"} {
+		defs, ok := m[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		names := make([]string, 0, len(defs))
+		for name := range defs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := s.compile(defs[name], ptr+"/"+key+"/"+escapePointer(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var err error
+	sub := func(key string) (*schemaNode, error) {
+		if v, ok := m[key]; ok {
+			return s.compile(v, ptr+"/"+key)
+		}
+		return nil, nil
+	}
+	subs := func(key string) ([]*schemaNode, error) {
+		v, ok := m[key]
+		if !ok {
+			return nil, nil
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s at %s must be an array", key, ptr)
+		}
+		nodes := make([]*schemaNode, len(list))
+		for i, item := range list {
+			if nodes[i], err = s.compile(item, fmt.Sprintf("%s/%s/%d", ptr, key, i)); err != nil {
+				return nil, err
+			}
+		}
+		return nodes, nil
+	}
+	number := func(key string) *float64 {
+		if f, ok := m[key].(float64); ok {
+			return &f
+		}
+		return nil
+	}
+	integer := func(key string) *int {
+		if f, ok := m[key].(float64); ok {
+			i := int(f)
+			return &i
+		}
+		return nil
+	}
+
+	switch t := m["type"].(type) {
+	case string:
+		node.types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				node.types = append(node.types, name)
+			}
+		}
+	}
+	if enum, ok := m["enum"].([]interface{}); ok {
+		node.enum = enum
+	}
+	if c, ok := m["const"]; ok {
+		node.constValue = &c
+	}
+
+	if props, ok := m["properties"].(map[string]interface{}); ok {
+		node.properties = make(map[string]*schemaNode)
+		for name, v := range props {
+			if node.properties[name], err = s.compile(v, ptr+"/properties/"+escapePointer(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req, ok := m["required"].([]interface{}); ok {
+		for _, v := range req {
+			if name, ok := v.(string); ok {
+				node.required = append(node.required, name)
+			}
+		}
+	}
+	if node.additional, err = sub("additionalProperties"); err != nil {
+		return nil, err
+	}
+	if props, ok := m["patternProperties"].(map[string]interface{}); ok {
+		node.patternProperties = make(map[*regexp.Regexp]*schemaNode)
+		for pattern, v := range props {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q at %s: %w", pattern, ptr, err)
+			}
+			if node.patternProperties[re], err = s.compile(v, ptr+"/patternProperties/"+escapePointer(pattern)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	node.minProperties = integer("minProperties")
+	node.maxProperties = integer("maxProperties")
+
+	if node.items, err = sub("items"); err != nil {
+		return nil, err
+	}
+	node.minItems = integer("minItems")
+	node.maxItems = integer("maxItems")
+	node.uniqueItems, _ = m["uniqueItems"].(bool)
+
+	node.minLength = integer("minLength")
+	node.maxLength = integer("maxLength")
+	if pattern, ok := m["pattern"].(string); ok {
+		if node.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q at %s: %w", pattern, ptr, err)
+		}
+	}
+	node.format, _ = m["format"].(string)
+
+	node.minimum = number("minimum")
+	node.maximum = number("maximum")
+	node.exclusiveMinimum = number("exclusiveMinimum")
+	node.exclusiveMaximum = number("exclusiveMaximum")
+	node.multipleOf = number("multipleOf")
+
+	if node.allOf, err = subs("allOf"); err != nil {
+		return nil, err
+	}
+	if node.anyOf, err = subs("anyOf"); err != nil {
+		return nil, err
+	}
+	if node.oneOf, err = subs("oneOf"); err != nil {
+		return nil, err
+	}
+	if node.not, err = sub("not"); err != nil {
+		return nil, err
+	}
+	if ref, ok := m["$ref"].(string); ok {
+		if !strings.HasPrefix(ref, "#") {
+			return nil, fmt.Errorf("unsupported $ref %q at %s: only references within the schema are supported", ref, ptr)
+		}
+		node.ref = ref
+		s.refs = append(s.refs, node)
+	}
+	return node, nil
+}
+
+// resolveRef compiles the subschema a $ref points to.
+func (s *schemaCompiler) resolveRef(ref string) (*schemaNode, error) {
+	if node, ok := s.cache[ref]; ok {
+		return node, nil
+	}
+	target := s.raw
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch t := target.(type) {
+		case map[string]interface{}:
+			target = t[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			target = t[i]
+		default:
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if target == nil {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return s.compile(target, ref)
+}
+
+// validate returns the violations of node, found at path, against sch.
+func (s *Schema) validate(sch *schemaNode, node *yaml.Node, path string) []SchemaViolation {
+	node = resolveAlias(node)
+	var violations []SchemaViolation
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, SchemaViolation{Path: path, Line: node.Line, Column: node.Column,
+			Message: fmt.Sprintf(format, args...), node: node})
+	}
+
+	if sch.always != nil {
+		if !*sch.always {
+			fail("not allowed")
+		}
+		return violations
+	}
+	if sch.refTo != nil {
+		violations = append(violations, s.validate(sch.refTo, node, path)...)
+	}
+
+	// Only scalars, and nodes compared against enum or const, need decoding; the
+	// type of mappings and sequences follows from their kind.
+	var value interface{}
+	if node.Kind == yaml.ScalarNode || sch.enum != nil || sch.constValue != nil {
+		value = nodeValue(node)
+	}
+	kind := jsonType(node, value)
+	if len(sch.types) > 0 && !typeMatches(sch.types, kind) {
+		fail("expected %s, got %s", strings.Join(sch.types, " or "), kind)
+		return violations
+	}
+	if sch.enum != nil {
+		found := false
+		for _, allowed := range sch.enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %s is not one of %s", describe(value), describe(sch.enum))
+		}
+	}
+	if sch.constValue != nil && !reflect.DeepEqual(*sch.constValue, value) {
+		fail("value %s must be %s", describe(value), describe(*sch.constValue))
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		violations = append(violations, s.validateObject(sch, node, path, fail)...)
+	case yaml.SequenceNode:
+		violations = append(violations, s.validateArray(sch, node, path, fail)...)
+	case yaml.ScalarNode:
+		validateScalar(sch, value, fail)
+	}
+
+	for _, sub := range sch.allOf {
+		violations = append(violations, s.validate(sub, node, path)...)
+	}
+	if len(sch.anyOf) > 0 {
+		matched := false
+		for _, sub := range sch.anyOf {
+			if len(s.validate(sub, node, path)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("does not match any of the allowed schemas")
+		}
+	}
+	if len(sch.oneOf) > 0 {
+		matches := 0
+		for _, sub := range sch.oneOf {
+			if len(s.validate(sub, node, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fail("must match exactly one of the allowed schemas, matched %d", matches)
+		}
+	}
+	if sch.not != nil && len(s.validate(sch.not, node, path)) == 0 {
+		fail("must not match the disallowed schema")
+	}
+	return violations
+}
+
+// validateObject applies the object keywords of sch to a mapping node.
+func (s *Schema) validateObject(sch *schemaNode, node *yaml.Node, path string, fail func(string, ...interface{})) []SchemaViolation {
+	var violations []SchemaViolation
+	count := len(node.Content) / 2
+	if sch.minProperties != nil && count < *sch.minProperties {
+		fail("must have at least %d properties", *sch.minProperties)
+	}
+	if sch.maxProperties != nil && count > *sch.maxProperties {
+		fail("must have at most %d properties", *sch.maxProperties)
+	}
+	for _, name := range sch.required {
+		if mappingValue(node, name) == nil {
+			fail("missing required property %q", name)
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		keyPath := joinPath(path, key, false)
+		matched := false
+		if prop, ok := sch.properties[key]; ok {
+			matched = true
+			violations = append(violations, s.validate(prop, value, keyPath)...)
+		}
+		for re, prop := range sch.patternProperties {
+			if re.MatchString(key) {
+				matched = true
+				violations = append(violations, s.validate(prop, value, keyPath)...)
+			}
+		}
+		if !matched && sch.additional != nil {
+			if sch.additional.always != nil && !*sch.additional.always {
+				keyNode := node.Content[i]
+				violations = append(violations, SchemaViolation{Path: keyPath, Line: keyNode.Line, Column: keyNode.Column,
+					Message: fmt.Sprintf("unknown property %q", key), node: keyNode})
+				continue
+			}
+			violations = append(violations, s.validate(sch.additional, value, keyPath)...)
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Line < violations[j].Line })
+	return violations
+}
+
+// validateArray applies the array keywords of sch to a sequence node.
+func (s *Schema) validateArray(sch *schemaNode, node *yaml.Node, path string, fail func(string, ...interface{})) []SchemaViolation {
+	var violations []SchemaViolation
+	if sch.minItems != nil && len(node.Content) < *sch.minItems {
+		fail("must have at least %d items", *sch.minItems)
+	}
+	if sch.maxItems != nil && len(node.Content) > *sch.maxItems {
+		fail("must have at most %d items", *sch.maxItems)
+	}
+	if sch.uniqueItems {
+		values := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			values[i] = nodeValue(item)
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(values[i], values[j]) {
+					fail("items %d and %d are equal", j, i)
+				}
+			}
+		}
+	}
+	if sch.items != nil {
+		for i, item := range node.Content {
+			violations = append(violations, s.validate(sch.items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return violations
+}
+
+// validateScalar applies the string and number keywords of sch to a scalar value.
+func validateScalar(sch *schemaNode, value interface{}, fail func(string, ...interface{})) {
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if sch.minLength != nil && length < *sch.minLength {
+			fail("must be at least %d characters long", *sch.minLength)
+		}
+		if sch.maxLength != nil && length > *sch.maxLength {
+			fail("must be at most %d characters long", *sch.maxLength)
+		}
+		if sch.pattern != nil && !sch.pattern.MatchString(v) {
+			fail("%q does not match pattern %q", v, sch.pattern.String())
+		}
+		if err := checkFormat(sch.format, v); err != nil {
+			fail("%v", err)
+		}
+	case float64:
+		if sch.minimum != nil && v < *sch.minimum {
+			fail("must be at least %v", *sch.minimum)
+		}
+		if sch.maximum != nil && v > *sch.maximum {
+			fail("must be at most %v", *sch.maximum)
+		}
+		if sch.exclusiveMinimum != nil && v <= *sch.exclusiveMinimum {
+			fail("must be greater than %v", *sch.exclusiveMinimum)
+		}
+		if sch.exclusiveMaximum != nil && v >= *sch.exclusiveMaximum {
+			fail("must be less than %v", *sch.exclusiveMaximum)
+		}
+		if sch.multipleOf != nil && *sch.multipleOf != 0 {
+			if q := v / *sch.multipleOf; q != math.Trunc(q) {
+				fail("must be a multiple of %v", *sch.multipleOf)
+			}
+		}
+	}
+}
+
+// checkFormat validates s against a named format.
+func checkFormat(format, s string) error {
+	if referenceRegex.MatchString(s) {
+		return nil
+	}
+	switch format {
+	case "uri", "url":
+		return validateURL(s)
+	case "username":
+		return validateUsername(s)
+	case "duration":
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+	case "regex":
+		if _, err := regexp.Compile(s); err != nil {
+			return fmt.Errorf("invalid regular expression %q", s)
+		}
+	}
+	return nil
+}
+
+// nodeValue decodes a node into JSON-like values: maps, slices, strings,
+// float64 numbers, booleans and nil.
+func nodeValue(node *yaml.Node) interface{} {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	return normalize(value)
+}
+
+// normalize converts decoded YAML values into JSON-like values.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+// jsonType returns the JSON type name of a node, using the decoded value of a scalar.
+func jsonType(node *yaml.Node, value interface{}) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	case float64:
+		if node.ShortTag() == "!!int" || (v == math.Trunc(v) && !math.IsInf(v, 0)) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// typeMatches reports whether a value of the given JSON type satisfies types.
+func typeMatches(types []string, kind string) bool {
+	for _, t := range types {
+		if t == kind || (t == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// describe formats a JSON-like value for error messages.
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = describe(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(value)
+}
+
+// escapePointer escapes a key for use in a JSON pointer.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestBuiltinSchema(t *testing.T) {
+	t.Run("Valid config", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte(`
+variables:
+  endpoints:
+    service1: "http://example.com"
+    hooks: "${endpoints.service1}/hooks"
+  secrets:
+    secret1: ""
+  users:
+    user1: "root"
+  paths:
+    path1: "~"
+callbacks:
+  - name: "callback1"
+    events: ["event1"]
+    timing: "pre"
+    target:
+      type: "file"
+      path: "some/path"
+    endpoints: ["service1"]
+`))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		if err := BuiltinSchema().Validate(doc); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("Reports every violation with its position", func(t *testing.T) {
+		doc, err := yamledit.Parse([]byte(`variables:
+  endpoints:
+    api: "not a url"
+  users:
+    admin: 42
+callbacks:
+  - name: "cb"
+    events: ["write"]
+    timing: "later"
+    target:
+      type: "file"
+      path: "/x"
+    endpoints: ["api"]
+  - events: "read"
+`))
+		if err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		err = BuiltinSchema().Validate(doc)
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Fatalf("expected *SchemaError, got %v", err)
+		}
+		want := []SchemaViolation{
+			{Path: "variables.endpoints.api", Line: 3, Column: 10},
+			{Path: "variables.users.admin", Line: 5, Column: 12},
+			{Path: "callbacks[0].timing", Line: 9, Column: 13},
+			{Path: "callbacks[1]", Line: 14, Column: 5},
+		}
+		for _, w := range want {
+			found := false
+			for _, v := range schemaErr.Violations {
+				if v.Path == w.Path && v.Line == w.Line && v.Column == w.Column {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected a violation at %s line %d column %d, got %v", w.Path, w.Line, w.Column, schemaErr.Violations)
+			}
+		}
+		if matched, _ := regexp.MatchString(`missing required property "timing"`, err.Error()); !matched {
+			t.Errorf("expected missing timing to be reported, got %v", err)
+		}
+		if matched, _ := regexp.MatchString(`callbacks\[1\]\.events .*expected array, got string`, err.Error()); !matched {
+			t.Errorf("expected wrong events type to be reported, got %v", err)
+		}
+	})
+}
+
+func TestCompileSchema(t *testing.T) {
+	schema, err := CompileSchema([]byte(`
+type: object
+required: [server]
+additionalProperties: false
+properties:
+  variables: true
+  callbacks: true
+  server:
+    $ref: "#/$defs/server"
+$defs:
+  server:
+    type: object
+    properties:
+      host: {type: string, pattern: "^[a-z.]+$"}
+      port: {type: integer, minimum: 1, maximum: 65535}
+      mode: {enum: [dev, prod]}
+      tags: {type: array, items: {type: string}, uniqueItems: true, maxItems: 2}
+      timeout: {type: string, format: duration}
+      hosts:
+        anyOf:
+          - {type: string}
+          - {type: array, items: {type: string}}
+`))
+	if err != nil {
+		t.Fatalf("CompileSchema returned error: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"Valid", "server:\n  host: example.com\n  port: 8080\n  mode: prod\n  tags: [a, b]\n  timeout: 5s\n  hosts: [a]\n", ""},
+		{"Missing required", "other: 1\n", `missing required property "server"`},
+		{"Unknown property", "server: {}\nextra: 1\n", `extra \(line 2, column 1\): unknown property "extra"`},
+		{"Wrong type", "server:\n  port: \"80\"\n", `server.port .*expected integer, got string`},
+		{"Out of range", "server:\n  port: 70000\n", `must be at most 65535`},
+		{"Pattern", "server:\n  host: Example\n", `does not match pattern`},
+		{"Enum", "server:\n  mode: test\n", `is not one of \["dev", "prod"\]`},
+		{"Unique items", "server:\n  tags: [a, a]\n", `items 0 and 1 are equal`},
+		{"Max items", "server:\n  tags: [a, b, c]\n", `at most 2 items`},
+		{"Format", "server:\n  timeout: soon\n", `invalid duration "soon"`},
+		{"AnyOf", "server:\n  hosts: 3\n", `server.hosts .*does not match any of the allowed schemas`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := yamledit.Parse([]byte(tc.yaml))
+			if err != nil {
+				t.Fatalf("failed to parse YAML: %v", err)
+			}
+			err = schema.Validate(doc)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error matching %q, got nil", tc.wantErr)
+			}
+			if matched, _ := regexp.MatchString(tc.wantErr, err.Error()); !matched {
+				t.Errorf("expected error matching %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Invalid schema", func(t *testing.T) {
+		if _, err := CompileSchema([]byte(`{"type": "object", "properties": {"a": {"pattern": "("}}}`)); err == nil {
+			t.Error("expected an error for an invalid pattern")
+		}
+		if _, err := CompileSchema([]byte(`{"$ref": "other.json#/a"}`)); err == nil {
+			t.Error("expected an error for an external $ref")
+		}
+		for schema, want := range map[string]string{
+			`{"properties": {"a": {"$ref": "#/$defs/missing"}}}`:                 `unresolvable $ref "#/$defs/missing"`,
+			`{"if": {"type": "string"}, "then": {"minLength": 1}}`:               `unsupported keyword "if" at #`,
+			`{"properties": {"a": {"type": "array", "contains": {"const": 1}}}}`: `unsupported keyword "contains" at #/properties/a`,
+			`{"$defs": {"unused": {"propertyNames": {"pattern": "^a"}}}}`:        `unsupported keyword "propertyNames" at #/$defs/unused`,
+		} {
+			_, err := CompileSchema([]byte(schema))
+			if err == nil || err.Error() != want {
+				t.Errorf("CompileSchema(%s): expected %q, got %v", schema, want, err)
+			}
+		}
+	})
+}
+
+func TestSchemaConcurrentUse(t *testing.T) {
+	schema, err := CompileSchema([]byte(`{
+  "$defs": {
+    "name": {"type": "string"},
+    "node": {"type": "object", "properties": {"child": {"$ref": "#/$defs/node"}, "name": {"$ref": "#/$defs/name"}}}
+  },
+  "properties": {"root": {"$ref": "#/$defs/node"}}
+}`))
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+	doc, err := yamledit.Parse([]byte("root:\n  name: a\n  child:\n    child:\n      name: 1\n"))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	builtin := BuiltinSchema()
+	config, err := yamledit.Parse([]byte(testCallbacksYAML))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var schemaErr *SchemaError
+			if err := schema.Validate(doc); !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 ||
+				schemaErr.Violations[0].Path != "root.child.child.name" {
+				t.Errorf("expected one violation at root.child.child.name, got %v", err)
+			}
+			if err := builtin.Validate(config); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// testCallbacksYAML is a valid configuration for the built-in schema.
+const testCallbacksYAML = `variables:
+  endpoints:
+    hook: "https://example.com/hook"
+callbacks:
+  - name: "audit"
+    events: ["file.write"]
+    timing: "pre"
+    target: {type: "directory", path: "/projects"}
+    endpoints: ["hook"]
+`
+
+func TestLoadWithSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "server:\n  port: \"80\"\n",
+	})
+	schema, err := CompileSchema([]byte(`{"properties": {"server": {"properties": {"port": {"type": "integer"}}}}}`))
+	if err != nil {
+		t.Fatalf("CompileSchema returned error: %v", err)
+	}
+	_, _, _, err = Load(filepath.Join(dir, "config.yaml"), nil, WithSchema(BuiltinSchema()), WithSchema(schema))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 || schemaErr.Violations[0].Path != "server.port" {
+		t.Errorf("expected a schema error for server.port, got %v", err)
+	}
+	if _, _, _, err := Load(filepath.Join(dir, "config.yaml"), nil, WithSchema(BuiltinSchema())); err != nil {
+		t.Errorf("expected the built-in schema to accept other sections, got %v", err)
+	}
+}
+
+func TestLoadWithSchemaEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "variables:\n  endpoints:\n    svc: \"TBD\"\n  paths:\n    root: \"/srv\"\n",
+	})
+	path := filepath.Join(dir, "config.yaml")
+	schema, err := CompileSchema([]byte(`{"properties": {"variables": {"properties": {"paths": {"properties": {"root": {"enum": ["/srv"]}}}}}}}`))
+	if err != nil {
+		t.Fatalf("CompileSchema returned error: %v", err)
+	}
+
+	t.Run("Schemas check overridden values", func(t *testing.T) {
+		t.Setenv("CONFIG_VARIABLES_ENDPOINTS_SVC", "https://staging.example.com")
+		_, vars, _, err := Load(path, nil, WithSchema(BuiltinSchema()), WithSchema(schema))
+		if err != nil {
+			t.Fatalf("expected the overridden endpoint to pass the schema, got %v", err)
+		}
+		if vars.Endpoints["svc"] != "https://staging.example.com" {
+			t.Errorf("expected overridden endpoint, got %q", vars.Endpoints["svc"])
+		}
+	})
+
+	t.Run("Overrides are schema-checked", func(t *testing.T) {
+		t.Setenv("CONFIG_VARIABLES_ENDPOINTS_SVC", "https://staging.example.com")
+		t.Setenv("CONFIG_VARIABLES_PATHS_ROOT", "/tmp")
+		_, _, _, err := Load(path, nil, WithSchema(schema))
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 || schemaErr.Violations[0].Path != "variables.paths.root" {
+			t.Errorf("expected a schema error for variables.paths.root, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sources records the file each node of a merged document was read from.
+type sources map[*yaml.Node]string
+
+// record attributes node and all of its descendants to file.
+func (s sources) record(node *yaml.Node, file string) {
+	if node == nil {
+		return
+	}
+	s[node] = file
+	for _, child := range node.Content {
+		s.record(child, file)
+	}
+}
+
+// in returns the position of node for use in error messages: " in <file>:<line>:<column>"
+// for a node with a known source, or " at line <line>, column <column>" otherwise.
+// Parts that are not known, such as the lines of TOML files, are left out.
+func (s sources) in(node *yaml.Node) string {
+	if node == nil {
+		return ""
+	}
+	return location(s[node], node.Line, node.Column)
+}
+
+// location formats a position like sources.in.
+func location(file string, line, column int) string {
+	switch {
+	case file != "":
+		return " in " + position(file, line, column)
+	case line > 0:
+		return " at " + position(file, line, column)
+	}
+	return ""
+}
+
+// position formats a file name and a line and column, leaving out unknown parts.
+func position(file string, line, column int) string {
+	switch {
+	case file == "":
+		return fmt.Sprintf("line %d, column %d", line, column)
+	case line > 0:
+		return fmt.Sprintf("%s:%d:%d", file, line, column)
+	}
+	return file
+}
+
+// files returns the sorted names of all recorded source files.
+func (s sources) files() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, file := range s {
+		if file != defaultSource && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
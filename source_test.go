@@ -0,0 +1,93 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSourcesIn(t *testing.T) {
+	withLine := &yaml.Node{Line: 3, Column: 5}
+	withoutLine := &yaml.Node{}
+	src := sources{withLine: "a.yaml", withoutLine: "b.toml"}
+
+	cases := []struct {
+		name string
+		src  sources
+		node *yaml.Node
+		want string
+	}{
+		{"File and position", src, withLine, " in a.yaml:3:5"},
+		{"File without position", src, withoutLine, " in b.toml"},
+		{"Position without file", nil, withLine, " at line 3, column 5"},
+		{"Nothing known", nil, withoutLine, ""},
+		{"Nil node", src, nil, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.src.in(c.node); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": `include: vars.yaml
+callbacks:
+  - name: "cb"
+    events: ["write"]
+    timing: "later"
+    target:
+      type: "file"
+      path: "${paths.missing}"
+    endpoints: ["service1", "unknown"]
+`,
+		"vars.yaml": `variables:
+  endpoints:
+    service1: "not a url"
+  users:
+    user1: "Root"
+`,
+	})
+	path := filepath.Join(dir, "config.yaml")
+	vars := filepath.Join(dir, "vars.yaml")
+
+	_, _, _, err := Load(path, nil)
+	if err == nil {
+		t.Fatal("expected errors, got nil")
+	}
+	for _, want := range []string{
+		`invalid endpoint for "service1" in ` + regexp.QuoteMeta(vars) + `:3:15:`,
+		`invalid username for "user1" in ` + regexp.QuoteMeta(vars) + `:5:12:`,
+		`invalid timing for callback "cb" in ` + regexp.QuoteMeta(path) + `:5:13:`,
+		`invalid target path for callback "cb" in ` + regexp.QuoteMeta(path) + `:8:13:`,
+		`unknown endpoint key for callback "cb" in ` + regexp.QuoteMeta(path) + `:9:29: "unknown"`,
+	} {
+		if !regexp.MustCompile(want).MatchString(err.Error()) {
+			t.Errorf("expected error matching %q, got %v", want, err)
+		}
+	}
+
+	t.Run("Schema violations name the file", func(t *testing.T) {
+		_, _, _, err := Load(path, nil, WithSchema(BuiltinSchema()))
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Fatalf("expected *SchemaError, got %v", err)
+		}
+		found := false
+		for _, v := range schemaErr.Violations {
+			if v.Path == "callbacks[0].timing" && v.File == path && v.Line == 5 {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected a violation for callbacks[0].timing in %s at line 5, got %v", path, err)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"name", "events", "timing", "target", "endpoints"}
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"timming", "timing"},
+		{"enpoints", "endpoints"},
+		{"event", "events"},
+		{"nmae", "name"},
+		{"priority", ""},
+		{"x", ""},
+	}
+	for _, c := range cases {
+		if got := suggest(c.name, candidates); got != c.want {
+			t.Errorf("suggest(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"timing", "timming", 1},
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b); got != c.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
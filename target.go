@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// targetTypes lists the valid values of CallbackTarget.Type.
+var targetTypes = []string{"file", "directory", "glob", "regex"}
+
+// compileTarget returns a function reporting whether a path matches a glob or regex
+// target, or an error if its pattern is invalid. It returns nil for other targets.
+//
+// Glob patterns use path.Match syntax within each path segment, and a "**" segment
+// matches any number of segments, including none. Regular expressions must match the
+// whole path.
+func compileTarget(target CallbackTarget) (func(string) bool, error) {
+	switch target.Type {
+	case "glob":
+		if err := validateGlob(target.Path); err != nil {
+			return nil, err
+		}
+		// Consecutive "**" segments match the same paths as a single one.
+		pattern := slices.CompactFunc(strings.Split(path.Clean(target.Path), "/"), func(a, b string) bool {
+			return a == "**" && b == "**"
+		})
+		return func(p string) bool {
+			return matchGlob(pattern, strings.Split(path.Clean(p), "/"))
+		}, nil
+	case "regex":
+		if _, err := regexp.Compile(target.Path); err != nil {
+			return nil, err
+		}
+		re := regexp.MustCompile(`^(?:` + target.Path + `)$`)
+		return func(p string) bool {
+			return re.MatchString(path.Clean(p))
+		}, nil
+	}
+	return nil, nil
+}
+
+// validateGlob checks the syntax of every segment of a glob pattern.
+func validateGlob(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty glob pattern")
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob segment %q: %w", segment, err)
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether the path segments match the pattern segments. It works
+// backwards from the last pattern segment, recording which tails of segments the
+// rest of the pattern matches, so it compares each pair of segments at most once
+// however many "**" the pattern has.
+func matchGlob(pattern, segments []string) bool {
+	// matches[j] reports whether pattern[i+1:] matches segments[j:].
+	matches := make([]bool, len(segments)+1)
+	matches[len(segments)] = true
+	for i := len(pattern) - 1; i >= 0; i-- {
+		next := make([]bool, len(segments)+1)
+		if pattern[i] == "**" {
+			// "**" matches no segment, or segments[j] and possibly more.
+			for j := len(segments); j >= 0; j-- {
+				next[j] = matches[j] || (j < len(segments) && next[j+1])
+			}
+		} else {
+			for j := 0; j < len(segments); j++ {
+				if matches[j+1] {
+					next[j], _ = path.Match(pattern[i], segments[j])
+				}
+			}
+		}
+		matches = next
+	}
+	return matches[0]
+}
+
+// globPrefix returns the leading segments of a glob pattern that contain no
+// wildcards, which every matching path starts with.
+func globPrefix(pattern string) string {
+	var literal []string
+	for _, segment := range strings.Split(path.Clean(pattern), "/") {
+		if strings.ContainsAny(segment, `*?[\`) {
+			break
+		}
+		literal = append(literal, segment)
+	}
+	return strings.Join(literal, "/")
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dropsite-ai/yamledit"
+)
+
+func TestCompileTarget(t *testing.T) {
+	cases := []struct {
+		target CallbackTarget
+		path   string
+		want   bool
+	}{
+		{CallbackTarget{Type: "glob", Path: "/projects/**/*.md"}, "/projects/README.md", true},
+		{CallbackTarget{Type: "glob", Path: "/projects/**/*.md"}, "/projects/a/b/c.md", true},
+		{CallbackTarget{Type: "glob", Path: "/projects/**/*.md"}, "/projects/a/b/c.txt", false},
+		{CallbackTarget{Type: "glob", Path: "/projects/**/*.md"}, "/other/a.md", false},
+		{CallbackTarget{Type: "glob", Path: "/projects/*/notes"}, "/projects/a/notes", true},
+		{CallbackTarget{Type: "glob", Path: "/projects/*/notes"}, "/projects/a/b/notes", false},
+		{CallbackTarget{Type: "glob", Path: "/logs/**"}, "/logs", true},
+		{CallbackTarget{Type: "glob", Path: "/logs/**"}, "/logs/2024/01/app.log", true},
+		{CallbackTarget{Type: "glob", Path: "/a/**/**/b"}, "/a/b", true},
+		{CallbackTarget{Type: "glob", Path: "/file-[0-9].txt"}, "/file-7.txt", true},
+		{CallbackTarget{Type: "regex", Path: `/projects/[^/]+/.*\.md`}, "/projects/a/b.md", true},
+		{CallbackTarget{Type: "regex", Path: `/projects/[^/]+/.*\.md`}, "/projects/a/b.md.bak", false},
+		{CallbackTarget{Type: "regex", Path: `\.md`}, "/a.md", false},
+		{CallbackTarget{Type: "regex", Path: `a|/b`}, "/b", true},
+	}
+	for _, c := range cases {
+		match, err := compileTarget(c.target)
+		if err != nil {
+			t.Fatalf("compileTarget(%+v) returned error: %v", c.target, err)
+		}
+		if got := match(c.path); got != c.want {
+			t.Errorf("%s %q matching %q = %v, want %v", c.target.Type, c.target.Path, c.path, got, c.want)
+		}
+	}
+
+	// Many "**" segments do not make matching blow up.
+	deep := "/" + strings.Repeat("d/", 30) + "y"
+	for _, pattern := range []string{"/**/**/**/**/**/**/**/x", "/**/d/**/d/**/d/**/d/**/d/**/x"} {
+		match, err := compileTarget(CallbackTarget{Type: "glob", Path: pattern})
+		if err != nil {
+			t.Fatalf("compileTarget(%q) returned error: %v", pattern, err)
+		}
+		if match(deep) {
+			t.Errorf("expected %q not to match %q", pattern, deep)
+		}
+		if !match(strings.TrimSuffix(deep, "y") + "x") {
+			t.Errorf("expected %q to match the path ending in x", pattern)
+		}
+	}
+
+	for _, target := range []CallbackTarget{{Type: "glob", Path: "/a/[b"}, {Type: "glob", Path: ""}, {Type: "regex", Path: "(a"}} {
+		if _, err := compileTarget(target); err == nil {
+			t.Errorf("expected an error for %+v", target)
+		}
+	}
+	if match, err := compileTarget(CallbackTarget{Type: "file", Path: "/a"}); match != nil || err != nil {
+		t.Errorf("expected nil for file targets, got %v", err)
+	}
+}
+
+func TestPatternTargets(t *testing.T) {
+	doc, err := yamledit.Parse([]byte(`callbacks:
+  - name: "docs"
+    events: ["file.write"]
+    timing: "post"
+    target: {type: "glob", path: "${paths.root}/**/*.md"}
+    endpoints: []
+  - name: "logs"
+    events: ["file.write"]
+    timing: "post"
+    target: {type: "regex", path: '/logs/\d+\.log'}
+    endpoints: []
+  - name: "bad-glob"
+    events: ["file.write"]
+    timing: "post"
+    target: {type: "glob", path: "/a/[b"}
+    endpoints: []
+  - name: "bad-regex"
+    events: ["file.write"]
+    timing: "post"
+    target: {type: "regex", path: "(a"}
+    endpoints: []
+`))
+	if err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	vars := &Variables{Paths: map[string]string{"root": "/projects"}}
+	_, err = ProcessCallbacks(doc, "callbacks", vars)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || !errors.Is(err, ErrInvalidPattern) {
+		t.Fatalf("expected 2 invalid patterns, got %v", err)
+	}
+	for _, want := range []string{
+		`invalid target pattern for callback "bad-glob" at line 15, column 34: invalid glob segment "\[b"`,
+		`invalid target pattern for callback "bad-regex" at line 20, column 35: error parsing regexp: missing closing \)`,
+	} {
+		if !regexp.MustCompile(want).MatchString(err.Error()) {
+			t.Errorf("expected error matching %q, got %v", want, err)
+		}
+	}
+
+	doc.Content[0].Content[1].Content = doc.Content[0].Content[1].Content[:2]
+	callbacks, err := ProcessCallbacks(doc, "callbacks", vars)
+	if err != nil {
+		t.Fatalf("ProcessCallbacks returned error: %v", err)
+	}
+	m := NewMatcher(callbacks)
+	for p, want := range map[string]string{
+		"/projects/a/README.md": "docs",
+		"/logs/20240101.log":    "logs",
+		"/logs/app.log":         "",
+		"/other/README.md":      "",
+	} {
+		got := ""
+		if matched := m.Match("file.write", "post", p); len(matched) == 1 {
+			got = matched[0].Name
+		} else if len(matched) > 1 {
+			got = "several"
+		}
+		if got != want {
+			t.Errorf("%s: expected %q, got %q", p, want, got)
+		}
+	}
+}
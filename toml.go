@@ -0,0 +1,286 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// bareKeyRegex matches TOML keys that can be written without quotes.
+var bareKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseTOML converts a TOML document into a YAML document node, keeping key order.
+// TOML positions are not available, so the nodes carry no line numbers.
+func parseTOML(data []byte) (*yaml.Node, error) {
+	var values map[string]interface{}
+	md, err := toml.Decode(string(data), &values)
+	if err != nil {
+		return nil, fmt.Errorf("parsing TOML: %w", err)
+	}
+	order := make(map[string]int)
+	for i, key := range md.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+	root, err := tomlNode(values, nil, order)
+	if err != nil {
+		return nil, err
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}, nil
+}
+
+// tomlNode converts a decoded TOML value at key into a node, ordering table keys
+// as they appeared in the document.
+func tomlNode(value interface{}, key toml.Key, order map[string]int) (*yaml.Node, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		position := func(k string) int {
+			if i, ok := order[append(key[:len(key):len(key)], k).String()]; ok {
+				return i
+			}
+			return math.MaxInt
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			pi, pj := position(keys[i]), position(keys[j])
+			if pi != pj {
+				return pi < pj
+			}
+			return keys[i] < keys[j]
+		})
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range keys {
+			child, err := tomlNode(v[k], append(key[:len(key):len(key)], k), order)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, child)
+		}
+		return node, nil
+	case []map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			child, err := tomlNode(item, key, order)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+		for _, item := range v {
+			child, err := tomlNode(item, key, order)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	}
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, fmt.Errorf("converting TOML value at %s: %w", key, err)
+	}
+	return &node, nil
+}
+
+// encodeTOML converts a YAML document node into TOML, keeping key order.
+func encodeTOML(doc *yaml.Node) ([]byte, error) {
+	root := documentRoot(doc)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("TOML documents must be a mapping at the top level")
+	}
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, nil, root, false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTOMLTable writes the mapping at path as a TOML table: its plain key/value
+// pairs first, followed by nested tables and arrays of tables. The header of a
+// table holding only nested tables is left out, as TOML defines it implicitly.
+func writeTOMLTable(buf *bytes.Buffer, path []string, mapping *yaml.Node, arrayItem bool) error {
+	type entry struct {
+		key   string
+		value *yaml.Node
+	}
+	var values, tables, arrays []entry
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i].Value, resolveAlias(mapping.Content[i+1])
+		switch {
+		case value.Kind == yaml.MappingNode:
+			tables = append(tables, entry{key, value})
+		case isTOMLArrayOfTables(value):
+			arrays = append(arrays, entry{key, value})
+		default:
+			values = append(values, entry{key, value})
+		}
+	}
+
+	if len(path) > 0 && (arrayItem || len(values) > 0 || len(tables)+len(arrays) == 0) {
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		if arrayItem {
+			fmt.Fprintf(buf, "[[%s]]\n", tomlKey(path...))
+		} else {
+			fmt.Fprintf(buf, "[%s]\n", tomlKey(path...))
+		}
+	}
+	for _, e := range values {
+		encoded, err := tomlValue(e.value)
+		if err != nil {
+			return fmt.Errorf("encoding %s as TOML: %w", strings.Join(append(path, e.key), "."), err)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(e.key), encoded)
+	}
+	for _, e := range tables {
+		if err := writeTOMLTable(buf, append(path[:len(path):len(path)], e.key), e.value, false); err != nil {
+			return err
+		}
+	}
+	for _, e := range arrays {
+		for _, item := range e.value.Content {
+			if err := writeTOMLTable(buf, append(path[:len(path):len(path)], e.key), resolveAlias(item), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTOMLArrayOfTables reports whether node is a non-empty sequence of mappings.
+func isTOMLArrayOfTables(node *yaml.Node) bool {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		if resolveAlias(item).Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlValue encodes a scalar, sequence or mapping as an inline TOML value.
+func tomlValue(node *yaml.Node) (string, error) {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			encoded, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, encoded)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case yaml.MappingNode:
+		items := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			encoded, err := tomlValue(node.Content[i+1])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(node.Content[i].Value)+" = "+encoded)
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	case yaml.ScalarNode:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return "", err
+		}
+		switch v := value.(type) {
+		case nil:
+			return "", fmt.Errorf("TOML has no null value")
+		case string:
+			return tomlString(v), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case int:
+			return strconv.Itoa(v), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case uint64:
+			return strconv.FormatUint(v, 10), nil
+		case float64:
+			switch {
+			case math.IsInf(v, 1):
+				return "inf", nil
+			case math.IsInf(v, -1):
+				return "-inf", nil
+			case math.IsNaN(v):
+				return "nan", nil
+			}
+			s := strconv.FormatFloat(v, 'g', -1, 64)
+			if !strings.ContainsAny(s, ".eE") {
+				s += ".0"
+			}
+			return s, nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		}
+		return "", fmt.Errorf("unsupported value %q", node.Value)
+	}
+	return "", fmt.Errorf("cannot encode node of kind %v", node.Kind)
+}
+
+// tomlKey joins key parts into a dotted TOML key, quoting parts where needed.
+func tomlKey(parts ...string) string {
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		if bareKeyRegex.MatchString(part) {
+			quoted[i] = part
+		} else {
+			quoted[i] = tomlString(part)
+		}
+	}
+	return strings.Join(quoted, ".")
+}
+
+// tomlString encodes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -20,7 +20,7 @@ func validateUsername(name string) error {
 func validateURL(u string) error {
 	parsed, err := url.Parse(u)
 	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
-		return fmt.Errorf("invalid URL: %q", u)
+		return fmt.Errorf("%w: %q", ErrInvalidURL, u)
 	}
 	return nil
 }
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Snapshot is a validated configuration published by a Watcher.
+type Snapshot struct {
+	Doc       *yaml.Node
+	Variables *Variables
+	Callbacks []CallbackDefinition
+}
+
+// Update is delivered to Watcher subscribers after every reload. Err is set when
+// the changed configuration failed to load or validate; Snapshot is then nil and
+// the previous snapshot stays current.
+type Update struct {
+	Snapshot *Snapshot
+	Err      error
+}
+
+// Watcher reloads a configuration file with Load whenever it, or a file it
+// includes, changes, and publishes the result to subscribers.
+type Watcher struct {
+	// PollInterval is how often the files are checked for changes. Run uses
+	// DefaultPollInterval if it is not positive.
+	PollInterval time.Duration
+	// Debounce is how long the files must stay unchanged before reloading,
+	// so a burst of writes causes a single reload.
+	Debounce time.Duration
+	// Inotify additionally uses inotify on Linux to notice changes without
+	// waiting for the next poll. It is ignored on other platforms.
+	Inotify bool
+
+	path        string
+	defaultYAML []byte
+	opts        *options
+
+	mu      sync.RWMutex
+	current *Snapshot
+	files   map[string]fileState // state of each file when current was loaded
+	subs    map[chan Update]struct{}
+}
+
+// DefaultPollInterval is the PollInterval of new Watchers.
+const DefaultPollInterval = time.Second
+
+// fileState identifies the version of a watched file by its contents, since
+// modification times are too coarse on some filesystems to tell apart two writes
+// of the same size.
+type fileState struct {
+	exists bool
+	size   int64
+	sum    [sha256.Size]byte
+}
+
+// NewWatcher loads the configuration at path as Load does and returns a Watcher
+// holding it. Call Run to start watching for changes.
+func NewWatcher(path string, defaultYAML []byte, opts ...Option) (*Watcher, error) {
+	w := &Watcher{
+		PollInterval: DefaultPollInterval,
+		Debounce:     100 * time.Millisecond,
+		path:         path,
+		defaultYAML:  defaultYAML,
+		opts:         newOptions(opts),
+		subs:         make(map[chan Update]struct{}),
+	}
+	snapshot, files, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.current, w.files = snapshot, files
+	return w, nil
+}
+
+// Current returns the most recent valid configuration.
+func (w *Watcher) Current() *Snapshot {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Subscribe returns a channel receiving an Update after every reload, and a
+// function that cancels the subscription. Only the latest update is kept for
+// a subscriber that falls behind.
+func (w *Watcher) Subscribe() (<-chan Update, func()) {
+	ch := make(chan Update, 1)
+	w.mu.Lock()
+	w.subs[ch] = struct{}{}
+	w.mu.Unlock()
+	return ch, func() {
+		w.mu.Lock()
+		delete(w.subs, ch)
+		w.mu.Unlock()
+	}
+}
+
+// Run watches the configuration until ctx is done, reloading it after changes
+// and publishing each result to subscribers. It returns ctx.Err().
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Inotify only wakes the loop up early; changes are still detected by
+	// comparing file states, so missed events are caught by the next poll.
+	var n *notifier
+	var notified <-chan struct{}
+	if w.Inotify {
+		if created, err := newNotifier(); err == nil {
+			n = created
+			defer n.close()
+			notified = n.events
+		}
+	}
+	watch := func(files map[string]fileState) map[string]fileState {
+		if n != nil {
+			n.watch(slices.Collect(maps.Keys(files)))
+		}
+		return files
+	}
+
+	seen := watch(w.watchedFiles())
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			debounce.Stop()
+			return ctx.Err()
+		case <-ticker.C:
+		case <-notified:
+		case <-debounce.C:
+			seen = watch(w.reload())
+			continue
+		}
+		if current := statFiles(slices.Collect(maps.Keys(seen))); !maps.EqualFunc(seen, current, fileState.equal) {
+			seen = current
+			debounce.Reset(w.Debounce)
+		}
+	}
+}
+
+// reload loads the configuration, publishes the result and returns the files
+// to watch from now on, with the state they were in when they were loaded.
+func (w *Watcher) reload() map[string]fileState {
+	snapshot, files, err := w.load()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil {
+		w.current, w.files = snapshot, files
+	}
+	// On errors, files holds the previous files as they were read, so the next
+	// change triggers another attempt.
+	update := Update{Snapshot: snapshot, Err: err}
+	for ch := range w.subs {
+		// Replace an update the subscriber has not received yet.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- update
+	}
+	return files
+}
+
+// load runs Load and returns the snapshot with the state of the files it was read from.
+// The states are taken before reading, so a write landing while the files are read
+// is noticed afterwards rather than taken as already loaded. When the configuration
+// includes files whose state was not taken yet, it is loaded again. On errors, the
+// states of the files the previous snapshot was read from are returned.
+func (w *Watcher) load() (*Snapshot, map[string]fileState, error) {
+	known := []string{w.path}
+	w.mu.RLock()
+	for file := range w.files {
+		if file != w.path {
+			known = append(known, file)
+		}
+	}
+	w.mu.RUnlock()
+	for {
+		states := statFiles(known)
+		doc, m, err := loadFile(w.path, w.defaultYAML, w.opts)
+		if err != nil {
+			return nil, states, err
+		}
+		// Watch the path itself even when the defaults were used, so creating it is noticed.
+		files := m.src.files()
+		if !slices.Contains(files, w.path) {
+			files = append(files, w.path)
+		}
+		if missing := slices.DeleteFunc(slices.Clone(files), func(file string) bool {
+			_, ok := states[file]
+			return ok
+		}); len(missing) > 0 {
+			known = append(known, missing...)
+			continue
+		}
+		doc, vars, callbacks, err := process(doc, m.src, w.opts)
+		if err != nil {
+			return nil, states, err
+		}
+		watched := make(map[string]fileState, len(files))
+		for _, file := range files {
+			watched[file] = states[file]
+		}
+		return &Snapshot{Doc: doc, Variables: vars, Callbacks: callbacks}, watched, nil
+	}
+}
+
+// watchedFiles returns the files the current snapshot was read from.
+func (w *Watcher) watchedFiles() map[string]fileState {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.files
+}
+
+// statFiles returns the current state of each file.
+func statFiles(files []string) map[string]fileState {
+	states := make(map[string]fileState, len(files))
+	for _, file := range files {
+		if data, err := os.ReadFile(file); err == nil {
+			states[file] = fileState{exists: true, size: int64(len(data)), sum: sha256.Sum256(data)}
+		} else {
+			states[file] = fileState{}
+		}
+	}
+	return states
+}
+
+// equal reports whether two file states are the same.
+func (s fileState) equal(other fileState) bool {
+	return s == other
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// notifier signals on events when inotify reports activity in the directories
+// of the watched files. Directories are watched rather than the files so that
+// editors replacing a file by renaming over it are noticed.
+type notifier struct {
+	events chan struct{}
+	fd     int
+	file   *os.File
+
+	mu   sync.Mutex
+	dirs map[string]bool
+}
+
+// newNotifier creates an inotify instance and starts reading its events.
+func newNotifier() (*notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	n := &notifier{
+		events: make(chan struct{}, 1),
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		dirs:   make(map[string]bool),
+	}
+	go n.read()
+	return n, nil
+}
+
+// read forwards inotify activity to events until the notifier is closed.
+func (n *notifier) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		if _, err := n.file.Read(buf); err != nil {
+			return
+		}
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch adds inotify watches for the directories of files not yet watched.
+func (n *notifier) watch(files []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if n.dirs[dir] {
+			continue
+		}
+		const mask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+			syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+		if _, err := syscall.InotifyAddWatch(n.fd, dir, mask); err == nil {
+			n.dirs[dir] = true
+		}
+	}
+}
+
+// close stops the notifier.
+func (n *notifier) close() {
+	n.file.Close()
+}
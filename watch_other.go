@@ -0,0 +1,19 @@
+//go:build !linux
+
+package config
+
+import "errors"
+
+// notifier is not available on this platform; Watcher falls back to polling.
+type notifier struct {
+	events chan struct{}
+}
+
+// newNotifier always fails on platforms without inotify.
+func newNotifier() (*notifier, error) {
+	return nil, errors.New("inotify is not supported on this platform")
+}
+
+func (n *notifier) watch(files []string) {}
+
+func (n *notifier) close() {}
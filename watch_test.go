@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	for _, inotify := range []bool{false, true} {
+		name := "Polling"
+		if inotify {
+			name = "Inotify"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.yaml")
+			writeFiles(t, dir, map[string]string{
+				"config.yaml": "include: [users.yaml]\n",
+				"users.yaml": `
+variables:
+  users:
+    user1: "root"
+`,
+			})
+
+			w, err := NewWatcher(path, nil)
+			if err != nil {
+				t.Fatalf("NewWatcher returned error: %v", err)
+			}
+			w.PollInterval = 10 * time.Millisecond
+			w.Debounce = 50 * time.Millisecond
+			w.Inotify = inotify
+			updates, unsubscribe := w.Subscribe()
+			defer unsubscribe()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- w.Run(ctx) }()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			next := func() Update {
+				t.Helper()
+				select {
+				case u := <-updates:
+					return u
+				case <-time.After(5 * time.Second):
+					t.Fatal("timed out waiting for update")
+				}
+				return Update{}
+			}
+
+			// Changes to included files are picked up; a burst of writes causes one reload.
+			for _, user := range []string{"alice", "bob", "carol"} {
+				writeFiles(t, dir, map[string]string{"users.yaml": "variables:\n  users:\n    user1: \"" + user + "\"\n"})
+			}
+			u := next()
+			if u.Err != nil {
+				t.Fatalf("unexpected reload error: %v", u.Err)
+			}
+			if got := u.Snapshot.Variables.Users["user1"]; got != "carol" {
+				t.Errorf("expected user1 to be 'carol', got %q", got)
+			}
+			if w.Current() != u.Snapshot {
+				t.Error("expected Current to return the published snapshot")
+			}
+			select {
+			case u := <-updates:
+				t.Fatalf("expected a single reload for the burst of writes, got another: %+v", u)
+			case <-time.After(10 * w.Debounce):
+			}
+
+			// Rewriting the file with contents of the same size is noticed, even if its
+			// modification time does not change.
+			users := filepath.Join(dir, "users.yaml")
+			info, err := os.Stat(users)
+			if err != nil {
+				t.Fatalf("failed to stat users.yaml: %v", err)
+			}
+			writeFiles(t, dir, map[string]string{"users.yaml": "variables:\n  users:\n    user1: \"danny\"\n"})
+			if err := os.Chtimes(users, info.ModTime(), info.ModTime()); err != nil {
+				t.Fatalf("failed to reset the modification time: %v", err)
+			}
+			if u := next(); u.Err != nil || u.Snapshot.Variables.Users["user1"] != "danny" {
+				t.Fatalf("expected user1 to be 'danny', got %+v", u)
+			}
+
+			// Invalid changes are reported and not published.
+			if err := os.WriteFile(filepath.Join(dir, "users.yaml"), []byte("variables:\n  users:\n    user1: \"Not Valid\"\n"), 0644); err != nil {
+				t.Fatalf("failed to write users.yaml: %v", err)
+			}
+			u = next()
+			if u.Err == nil || !regexp.MustCompile(`invalid username`).MatchString(u.Err.Error()) {
+				t.Errorf("expected invalid username error, got %v", u.Err)
+			}
+			if got := w.Current().Variables.Users["user1"]; got != "danny" {
+				t.Errorf("expected previous snapshot to stay current, got user1 %q", got)
+			}
+		})
+	}
+}
+
+func TestWatcherPollInterval(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config.yaml": "variables:\n  users:\n    user1: \"root\"\n"})
+	w, err := NewWatcher(filepath.Join(dir, "config.yaml"), nil)
+	if err != nil {
+		t.Fatalf("NewWatcher returned error: %v", err)
+	}
+	w.PollInterval = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected Run to fall back to the default poll interval, got %v", err)
+	}
+}
+
+func TestNewWatcherInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config.yaml": "variables:\n  endpoints:\n    service1: \"invalid-url\"\n"})
+	if _, err := NewWatcher(filepath.Join(dir, "config.yaml"), nil); err == nil {
+		t.Fatal("expected error for an invalid initial config, got nil")
+	}
+}